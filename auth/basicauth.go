@@ -4,12 +4,11 @@ import (
 	"github.com/cqroot/prompt"
 	"github.com/cqroot/prompt/input"
 	failed "github.com/ghokun/coyote/error"
-	"github.com/urfave/cli/v3"
 	"net/url"
 )
 
-func Basic(cli *cli.Command) (amqpUrl *url.URL, err error) {
-	amqpUrl, err = url.Parse(cli.String("url"))
+func Basic(rawUrl string) (amqpUrl *url.URL, err error) {
+	amqpUrl, err = url.Parse(rawUrl)
 	if err != nil {
 		return nil, failed.Because("failed to parse provided url", err)
 	}
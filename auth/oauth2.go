@@ -16,6 +16,7 @@ import (
 	"github.com/cqroot/prompt/choose"
 	"github.com/fatih/color"
 	failed "github.com/ghokun/coyote/error"
+	"github.com/ghokun/coyote/management"
 	"github.com/hashicorp/go-secure-stdlib/base62"
 	"github.com/pkg/browser"
 	"github.com/urfave/cli/v3"
@@ -40,12 +41,16 @@ type OpenidConfiguration struct {
 	TokenEndpoint         string `json:"token_endpoint"`
 }
 
-func OAuth2(cli *cli.Command) (amqpUrl *url.URL, err error) {
-	amqpUrl, err = url.Parse(cli.String("url"))
+func OAuth2(cli *cli.Command, rawUrl string, managementUrl string) (amqpUrl *url.URL, err error) {
+	amqpUrl, err = url.Parse(rawUrl)
 	if err != nil {
 		return nil, failed.Because("failed to parse provided url", err)
 	}
-	oauthConfig, err := fetchAuthConfig(amqpUrl)
+	apiUrl, err := management.APIURL(amqpUrl, managementUrl)
+	if err != nil {
+		return nil, err
+	}
+	oauthConfig, err := fetchAuthConfig(apiUrl)
 	if err != nil {
 		return nil, err
 	}
@@ -89,14 +94,8 @@ func OAuth2(cli *cli.Command) (amqpUrl *url.URL, err error) {
 	return amqpUrl, nil
 }
 
-func fetchAuthConfig(amqpUrl *url.URL) (authConfig *OAuthConfig, err error) {
-	var apiScheme string
-	if amqpUrl.Scheme == "amqps" {
-		apiScheme = "https"
-	} else {
-		apiScheme = "http"
-	}
-	authApiUrl := apiScheme + "://" + amqpUrl.Host + "/api/auth"
+func fetchAuthConfig(apiUrl *url.URL) (authConfig *OAuthConfig, err error) {
+	authApiUrl := apiUrl.JoinPath("auth").String()
 	resp, err := http.Get(authApiUrl)
 	if err != nil {
 		return nil, failed.Because("failure while connecting to "+authApiUrl, err)
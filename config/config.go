@@ -0,0 +1,71 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ghokun/coyote/contract"
+	failed "github.com/ghokun/coyote/error"
+	"github.com/ghokun/coyote/redact"
+)
+
+// DefaultProfile is used unless another profile is selected.
+const DefaultProfile = "default"
+
+// Config is read from a JSON file holding named profiles, e.g.
+//
+//	{"profiles": {"prod": {"salt": "s3cret", "redact": [{"header": "authorization", "action": "drop"}]}}}
+type Config struct {
+	Profiles map[string]Profile `json:"profiles"`
+}
+
+// Profile holds the settings applied to captures run with it.
+type Profile struct {
+	// Salt is prepended to values before they are hashed by redaction rules.
+	Salt   string        `json:"salt,omitempty"`
+	Redact []redact.Rule `json:"redact,omitempty"`
+	// Contracts are validated against while capturing, their schema files are relative to the configuration file.
+	Contracts []contract.Contract `json:"contracts,omitempty"`
+	// Formats are named templates captured messages are printed with, selected by name with --format.
+	Formats map[string]string `json:"formats,omitempty"`
+}
+
+// DefaultFilename returns coyote/config.json in the user configuration directory, e.g. ~/.config on Linux.
+func DefaultFilename() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "coyote", "config.json")
+}
+
+// Load reads the configuration file. A file that does not exist is an empty configuration unless required.
+func Load(filename string, required bool) (*Config, error) {
+	content, err := os.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) && !required {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, failed.Because("failed to read config "+filename, err)
+	}
+	var config Config
+	if err := json.Unmarshal(content, &config); err != nil {
+		return nil, failed.Because("failed to decode config "+filename, err)
+	}
+	return &config, nil
+}
+
+// Profile returns the named profile. Profiles that are not configured are empty unless required.
+func (c *Config) Profile(name string, required bool) (Profile, error) {
+	profile, found := c.Profiles[name]
+	if !found && required {
+		return Profile{}, failed.Because("unknown profile "+name+", expected one of "+strings.Join(slices.Sorted(maps.Keys(c.Profiles)), ", "), nil)
+	}
+	return profile, nil
+}
@@ -0,0 +1,38 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config.json")
+	if conf, err := Load(filename, false); err != nil || len(conf.Profiles) != 0 {
+		t.Errorf("expected a missing optional config to be empty, got %+v, %v", conf, err)
+	}
+	if _, err := Load(filename, true); err == nil {
+		t.Error("expected an error for a missing required config")
+	}
+
+	content := `{"profiles": {"prod": {"salt": "s", "redact": [{"header": "authorization", "action": "drop"}],
+	  "contracts": [{"exchange": "orders", "routing_key": "order.*", "schema": "schemas/order.json"}]}}}`
+	if err := os.WriteFile(filename, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	conf, err := Load(filename, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prod, err := conf.Profile("prod", true)
+	if err != nil || prod.Salt != "s" || len(prod.Redact) != 1 || prod.Redact[0].Header != "authorization" ||
+		len(prod.Contracts) != 1 || prod.Contracts[0].Schema != "schemas/order.json" {
+		t.Errorf("unexpected profile %+v, %v", prod, err)
+	}
+	if p, err := conf.Profile(DefaultProfile, false); err != nil || len(p.Redact) != 0 {
+		t.Errorf("expected an unconfigured default profile to be empty, got %+v, %v", p, err)
+	}
+	if _, err := conf.Profile("staging", true); err == nil {
+		t.Error("expected an error for an unknown profile")
+	}
+}
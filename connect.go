@@ -3,28 +3,191 @@ package main
 import (
 	"crypto/tls"
 	"log"
+	"net"
 	"net/url"
+	"strconv"
+	"strings"
 
+	"github.com/fatih/color"
 	"github.com/ghokun/coyote/auth"
 	failed "github.com/ghokun/coyote/error"
+	"github.com/ghokun/coyote/failover"
+	"github.com/ghokun/coyote/management"
 	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/urfave/cli/v3"
 )
 
 func connect(cli *cli.Command) (connection *amqp.Connection, err error) {
-	var amqpUrl *url.URL
+	amqpUrl, err := authenticate(cli)
+	if err != nil {
+		return nil, err
+	}
+	return dial(cli, amqpUrl)
+}
+
+// source is a broker to capture from, named after the host of its url unless given as name=url.
+type source struct {
+	name   string
+	rawUrl string
+}
+
+func parseSource(spec string) source {
+	if name, rawUrl, found := strings.Cut(spec, "="); found && !strings.Contains(name, "://") {
+		return source{name: name, rawUrl: rawUrl}
+	}
+	if u, err := url.Parse(spec); err == nil && u.Host != "" {
+		return source{name: u.Host, rawUrl: spec}
+	}
+	return source{name: spec, rawUrl: spec}
+}
+
+// sources returns the brokers of every --url.
+func sources(cli *cli.Command) (all []source, err error) {
+	specs := cli.StringSlice("url")
+	if len(specs) == 0 {
+		return nil, failed.Because("url must be set", nil)
+	}
+	names := map[string]bool{}
+	for _, spec := range specs {
+		s := parseSource(spec)
+		if names[s.name] {
+			return nil, failed.Because("source "+s.name+" is given twice, sources can be named as name=url", nil)
+		}
+		names[s.name] = true
+		all = append(all, s)
+	}
+	return all, nil
+}
+
+// authenticate returns the url with the credentials to connect with, prompting for them when needed.
+// Only captures read from several urls, every other command connects to a single one.
+func authenticate(cli *cli.Command) (amqpUrl *url.URL, err error) {
+	all, err := sources(cli)
+	if err != nil {
+		return nil, err
+	}
+	if len(all) > 1 {
+		return nil, failed.Because("only a single url can be set for "+cli.Name, nil)
+	}
+	return authenticateSource(cli, all[0])
+}
+
+// authenticateSource returns the url of the source with the credentials to connect with.
+func authenticateSource(cli *cli.Command, src source) (amqpUrl *url.URL, err error) {
 	if cli.Bool("oauth") {
 		if !cli.IsSet("redirect-url") {
 			return nil, failed.Because("redirect-url must be set for OAuth 2.0", err)
 		}
-		log.Printf("🔑 Using OAuth 2.0 authentication")
-		amqpUrl, err = auth.OAuth2(cli)
+		log.Printf("🔑 Using OAuth 2.0 authentication for %s", color.YellowString(src.name))
+		amqpUrl, err = auth.OAuth2(cli, src.rawUrl, managementUrl(cli))
 	} else {
-		log.Printf("🔑 Using basic authentication")
-		amqpUrl, err = auth.Basic(cli)
+		log.Printf("🔑 Using basic authentication for %s", color.YellowString(src.name))
+		amqpUrl, err = auth.Basic(src.rawUrl)
 	}
 	if err != nil {
 		return nil, err
 	}
-	return amqp.DialTLS(amqpUrl.String(), &tls.Config{InsecureSkipVerify: cli.Bool("insecure")})
+	return amqpUrl, nil
+}
+
+// reconnectAttempts is how many times a lost capture connection is retried, about a minute with the default interval.
+const reconnectAttempts = 12
+
+func dial(cli *cli.Command, amqpUrl *url.URL) (connection *amqp.Connection, err error) {
+	config, dialUrl, err := dialConfig(cli, amqpUrl)
+	if err != nil {
+		return nil, err
+	}
+	return amqp.DialConfig(dialUrl, config)
+}
+
+// dialRecovering connects like dial, and reconnects when the connection is lost. Queues, bindings and consumers
+// declared on the connection are recovered, and deliveries continue on the same channels.
+func dialRecovering(cli *cli.Command, amqpUrl *url.URL) (connection *amqp.Connection, err error) {
+	config, dialUrl, err := dialConfig(cli, amqpUrl)
+	if err != nil {
+		return nil, err
+	}
+	config.Recovery = &amqp.Recovery{
+		ReconnectionConfig: &amqp.ReconnectionConfig{
+			MaxRetryCount: reconnectAttempts,
+			RetryInterval: amqp.DefaultRetryInterval,
+		},
+	}
+	return amqp.DialConfig(dialUrl, config)
+}
+
+// dialConfig returns the connection configuration and the url to dial. Given cluster nodes, connections fail over
+// to the first reachable one, which is also how reconnections pick their node.
+func dialConfig(cli *cli.Command, amqpUrl *url.URL) (config amqp.Config, dialUrl string, err error) {
+	config.TLSClientConfig = &tls.Config{InsecureSkipVerify: cli.Bool("insecure")}
+	if !cli.IsSet("node") && !cli.IsSet("node-srv") {
+		return config, amqpUrl.String(), nil
+	}
+	if cli.IsSet("node") && cli.IsSet("node-srv") {
+		return config, "", failed.Because("only one of node and node-srv can be set", nil)
+	}
+	if len(cli.StringSlice("url")) > 1 {
+		return config, "", failed.Because("cluster nodes can only be given for a single url", nil)
+	}
+	var random bool
+	switch cli.String("node-order") {
+	case "ordered":
+	case "random":
+		random = true
+	default:
+		return config, "", failed.Because("unknown node order "+cli.String("node-order"), nil)
+	}
+	var nodes []string
+	if cli.IsSet("node-srv") {
+		nodes, err = failover.Lookup(cli.String("node-srv"))
+		if err != nil {
+			return config, "", err
+		}
+	} else {
+		// The host of the url is the first node, the others are tried when it is not reachable.
+		uri, err := amqp.ParseURI(amqpUrl.String())
+		if err != nil {
+			return config, "", failed.Because("failed to parse provided url", err)
+		}
+		nodes = append([]string{net.JoinHostPort(uri.Host, strconv.Itoa(uri.Port))}, cli.StringSlice("node")...)
+	}
+	// Nodes of amqps urls are handshaken by the dialer, so that each is verified by its own host name, and the url
+	// dialed is switched to amqp for the client not to start another handshake. The dialer ignores its address.
+	var nodeTLS *tls.Config
+	dialed := *amqpUrl
+	if amqpUrl.Scheme == "amqps" {
+		nodeTLS, dialed.Scheme = config.TLSClientConfig, "amqp"
+	}
+	dialer, err := failover.New(nodes, random, nodeTLS)
+	if err != nil {
+		return config, "", err
+	}
+	config.Dial = dialer.Dial
+	return config, dialed.String(), nil
+}
+
+// vhostUrl returns the url connecting to the given vhost with the same credentials.
+func vhostUrl(amqpUrl *url.URL, vhost string) *url.URL {
+	u := *amqpUrl
+	u.Path = "/" + vhost
+	u.RawPath = "/" + url.PathEscape(vhost)
+	return &u
+}
+
+// managementUrl returns --management-url. It belongs to a single --url, so several sources use the API on their own hosts.
+func managementUrl(cli *cli.Command) string {
+	if len(cli.StringSlice("url")) > 1 {
+		return ""
+	}
+	return cli.String("management-url")
+}
+
+// managementClient creates a management API client that shares the authenticated url with AMQP connections.
+func managementClient(cli *cli.Command, amqpUrl *url.URL) (client *management.Client, err error) {
+	apiUrl, err := management.APIURL(amqpUrl, managementUrl(cli))
+	if err != nil {
+		return nil, err
+	}
+	return management.New(apiUrl, amqpUrl, cli.Bool("oauth"), cli.Bool("insecure")), nil
 }
@@ -0,0 +1,117 @@
+package contract
+
+import (
+	"bytes"
+	"errors"
+	"path"
+	"slices"
+	"strings"
+
+	failed "github.com/ghokun/coyote/error"
+	"github.com/ghokun/coyote/routing"
+	"github.com/ghokun/coyote/store"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// Contract is the JSON Schema bodies published to an exchange with matching routing keys must conform to.
+type Contract struct {
+	// Exchange the contract applies to, every exchange if empty.
+	Exchange string `json:"exchange,omitempty"`
+	// RoutingKey is a topic pattern, e.g. order.*.
+	RoutingKey string `json:"routing_key"`
+	// Schema is the JSON Schema file.
+	Schema string `json:"schema"`
+}
+
+// Parse parses a contract written as routing-key-pattern=schema-file, which applies to every exchange.
+func Parse(text string) (Contract, error) {
+	pattern, schema, found := strings.Cut(text, "=")
+	if !found || pattern == "" || schema == "" {
+		return Contract{}, failed.Because("contract must be written as routing-key-pattern=schema-file: "+text, nil)
+	}
+	return Contract{RoutingKey: pattern, Schema: schema}, nil
+}
+
+type compiled struct {
+	Contract
+	schema *jsonschema.Schema
+}
+
+// Validator validates bodies against the first contract matching their exchange and routing key.
+type Validator struct {
+	contracts []compiled
+}
+
+// New compiles the schemas of the contracts, it returns nil when there are none.
+func New(contracts []Contract) (*Validator, error) {
+	if len(contracts) == 0 {
+		return nil, nil
+	}
+	compiler := jsonschema.NewCompiler()
+	v := &Validator{}
+	for _, c := range contracts {
+		if c.RoutingKey == "" || c.Schema == "" {
+			return nil, failed.Because("contracts require a routing key pattern and a schema", nil)
+		}
+		schema, err := compiler.Compile(c.Schema)
+		if err != nil {
+			return nil, failed.Because("failed to compile schema "+c.Schema, err)
+		}
+		v.contracts = append(v.contracts, compiled{Contract: c, schema: schema})
+	}
+	return v, nil
+}
+
+// Validate validates a body against the contract of its exchange and routing key. It returns nil when no contract
+// applies, and so does a nil validator.
+func (v *Validator) Validate(exchange string, routingKey string, body []byte) *store.Validation {
+	if v == nil {
+		return nil
+	}
+	for _, c := range v.contracts {
+		if (c.Exchange != "" && c.Exchange != exchange) || !routing.TopicMatch(c.RoutingKey, routingKey) {
+			continue
+		}
+		validation := &store.Validation{Contract: c.Schema, Valid: true}
+		document, err := jsonschema.UnmarshalJSON(bytes.NewReader(body))
+		if err != nil {
+			validation.Valid, validation.Errors = false, []string{"body is not JSON"}
+			return validation
+		}
+		if err := c.schema.Validate(document); err != nil {
+			validation.Valid, validation.Errors = false, violations(err)
+		}
+		return validation
+	}
+	return nil
+}
+
+// violations lists where and which keyword of a schema a document violates, as /path: keyword sorted by path, since
+// the validator reports properties in no particular order. The messages of the validator are left out, as they may
+// quote values of the document, e.g. of fields that are redacted before messages are stored or printed.
+func violations(err error) []string {
+	var invalid *jsonschema.ValidationError
+	if !errors.As(err, &invalid) {
+		return []string{"body does not conform to its contract"}
+	}
+	var messages []string
+	for _, unit := range invalid.BasicOutput().Errors {
+		if unit.Error == nil {
+			continue
+		}
+		location := unit.InstanceLocation
+		if location == "" {
+			location = "/"
+		}
+		keyword := strings.Join(unit.Error.Kind.KeywordPath(), "/")
+		if keyword == "" {
+			keyword = path.Base(unit.KeywordLocation)
+		}
+		messages = append(messages, location+": "+keyword)
+	}
+	if len(messages) == 0 {
+		return []string{"body does not conform to its contract"}
+	}
+	slices.Sort(messages)
+	return messages
+}
@@ -0,0 +1,89 @@
+package contract
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ghokun/coyote/store"
+)
+
+func TestParse(t *testing.T) {
+	if c, err := Parse("order.*=order.json"); err != nil || c != (Contract{RoutingKey: "order.*", Schema: "order.json"}) {
+		t.Errorf("expected order.* validated against order.json, got %+v, %v", c, err)
+	}
+	for _, text := range []string{"order.*", "=order.json", "order.*="} {
+		if _, err := Parse(text); err == nil {
+			t.Errorf("expected %q to fail", text)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	order := filepath.Join(dir, "order.json")
+	if err := os.WriteFile(order, []byte(`{"type":"object","required":["id"],"properties":{"id":{"type":"integer"},
+	  "items":{"type":"array","items":{"type":"object","required":["sku"]}}}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	audit := filepath.Join(dir, "audit.json")
+	if err := os.WriteFile(audit, []byte(`{"type":"string"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	v, err := New([]Contract{{Exchange: "orders", RoutingKey: "order.*", Schema: order}, {RoutingKey: "#", Schema: audit}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		exchange   string
+		routingKey string
+		body       string
+		expected   *store.Validation
+	}{
+		{"orders", "order.created", `{"id":1,"items":[{"sku":"a"}]}`, &store.Validation{Contract: order, Valid: true}},
+		{"orders", "order.created", `{"id":"1","items":[{}]}`, &store.Validation{Contract: order, Errors: []string{
+			"/id: type",
+			"/items/0: required",
+		}}},
+		{"orders", "order.created", `not json`, &store.Validation{Contract: order, Errors: []string{"body is not JSON"}}},
+		{"billing", "order.created", `"paid"`, &store.Validation{Contract: audit, Valid: true}},
+	}
+	for _, test := range tests {
+		if validation := v.Validate(test.exchange, test.routingKey, []byte(test.body)); !reflect.DeepEqual(validation, test.expected) {
+			t.Errorf("expected %s %s %s to validate as %+v, got %+v", test.exchange, test.routingKey, test.body, test.expected, validation)
+		}
+	}
+
+	unmatched, err := New([]Contract{{RoutingKey: "order.*", Schema: order}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if validation := unmatched.Validate("orders", "user.created", []byte("{}")); validation != nil {
+		t.Errorf("expected no contract to apply, got %+v", validation)
+	}
+	if validation := (*Validator)(nil).Validate("orders", "order.created", []byte("{}")); validation != nil {
+		t.Errorf("expected a nil validator to validate nothing, got %+v", validation)
+	}
+	if _, err := New([]Contract{{RoutingKey: "#", Schema: filepath.Join(dir, "missing.json")}}); err == nil {
+		t.Error("expected a missing schema to fail")
+	}
+}
+
+func TestViolationsHideValues(t *testing.T) {
+	token := filepath.Join(t.TempDir(), "token.json")
+	if err := os.WriteFile(token, []byte(`{"type":"object","properties":{"token":{"type":"string","pattern":"^tok_"},
+	  "amount":{"type":"number","maximum":100}}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	v, err := New([]Contract{{RoutingKey: "#", Schema: token}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Bodies are validated before fields such as the token are redacted, so violations must not quote them.
+	validation := v.Validate("payments", "payment.created", []byte(`{"token":"s3cr3t","amount":4242}`))
+	expected := &store.Validation{Contract: token, Errors: []string{"/amount: maximum", "/token: pattern"}}
+	if !reflect.DeepEqual(validation, expected) {
+		t.Errorf("expected %+v, got %+v", expected, validation)
+	}
+}
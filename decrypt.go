@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/ghokun/coyote/encryption"
+	failed "github.com/ghokun/coyote/error"
+	"github.com/urfave/cli/v3"
+)
+
+// encryptedExtension is left out when telling the format of an encrypted file by its extension.
+const encryptedExtension = ".enc"
+
+const decryptUsage = `coyote decrypt [options] FILE
+
+Decrypts a file encrypted by coyote export --encrypt, for tools that cannot read it encrypted.
+
+Examples:
+# Decrypt an export with a key read from a file
+coyote decrypt --key-file coyote.key --output events.jsonl events.jsonl.enc
+
+# Count the events of an encrypted export, prompting for its passphrase
+coyote decrypt --key-prompt events.jsonl.enc | wc -l`
+
+func decryptCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "decrypt",
+		Usage:     "Decrypts an encrypted export.",
+		UsageText: decryptUsage,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "output",
+				Usage: "File to write the decrypted content to, standard output if omitted.",
+			},
+		},
+		Action: decryptFile,
+	}
+}
+
+func decryptFile(_ context.Context, cli *cli.Command) error {
+	if cli.NArg() != 1 {
+		return failed.Because("a single file to decrypt is required", nil)
+	}
+	path := cli.Args().First()
+	secret, err := encryptionKey(cli)
+	if err != nil {
+		return err
+	}
+	if secret == nil {
+		return failed.Because("a key is required with --key-file, --key-env or --key-prompt", nil)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return failed.Because("failed to open "+path, err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Fatal(failed.Because("failed to close "+path, err))
+		}
+	}()
+	buffered := bufio.NewReader(file)
+	if !encryption.IsEncrypted(buffered) {
+		return failed.Because(path+" is not encrypted", nil)
+	}
+	decrypted, err := encryption.NewReader(buffered, secret)
+	if err != nil {
+		return err
+	}
+
+	if !cli.IsSet("output") {
+		if _, err := io.Copy(cli.Root().Writer, decrypted); err != nil {
+			return failed.Because("failed to decrypt "+path, err)
+		}
+		return nil
+	}
+	output, err := os.Create(cli.String("output"))
+	if err != nil {
+		return failed.Because("failed to create "+cli.String("output"), err)
+	}
+	if _, err := io.Copy(output, decrypted); err != nil {
+		_ = output.Close()
+		return failed.Because("failed to decrypt "+path, err)
+	}
+	if err := output.Close(); err != nil {
+		return failed.Because("failed to write "+cli.String("output"), err)
+	}
+	log.Printf("🔓 Decrypted %s to %s", color.YellowString(path), color.YellowString(cli.String("output")))
+	return nil
+}
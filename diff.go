@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/ghokun/coyote/diff"
+	failed "github.com/ghokun/coyote/error"
+	"github.com/ghokun/coyote/render"
+	"github.com/ghokun/coyote/store"
+	"github.com/urfave/cli/v3"
+)
+
+const diffUsage = `coyote diff [options] LEFT RIGHT
+
+Compares two stores, pairing their messages by a key. Reports messages of LEFT missing from RIGHT,
+extra messages of RIGHT and the fields of paired messages that differ, exiting with 1 when they differ.
+Messages sharing a key are paired in the order they were captured.
+
+Keys:
+ message-id     # Message id property
+ correlation-id # Correlation id property
+ routing-key    # Routing key, pairing the nth message of every routing key
+ body.<path>    # JSON body field, e.g. body.order.id
+
+Examples:
+# Compare what was published before and after a deployment by message id
+coyote diff before.sqlite after.sqlite
+
+# Compare orders by their id, ignoring a timestamp header and the creation time in the body
+coyote diff --key body.order.id --ignore header.timestamp --ignore body.order.created_at before.sqlite after.sqlite
+
+# Report differences as JSON
+coyote diff --key routing-key --format json before.sqlite after.sqlite`
+
+func diffCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "diff",
+		Usage:     "Compares the messages of two stores by a key.",
+		UsageText: diffUsage,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "key",
+				Value: "message-id",
+				Usage: "Key pairing messages, one of message-id, correlation-id, routing-key or body.<path>.",
+			},
+			&cli.StringSliceFlag{
+				Name:  "ignore",
+				Usage: "Field to leave out of comparisons, e.g. header.timestamp or body.order.created_at.",
+			},
+			&cli.StringFlag{
+				Name:  "format",
+				Value: "text",
+				Usage: "Report format, one of text or json.",
+			},
+		},
+		Action: diffStores,
+	}
+}
+
+func diffStores(_ context.Context, cli *cli.Command) error {
+	if cli.NArg() != 2 {
+		return failed.Because("two stores are required", nil)
+	}
+	key, err := diff.ParseKey(cli.String("key"))
+	if err != nil {
+		return err
+	}
+	view, err := bodyView(cli)
+	if err != nil {
+		return err
+	}
+	var printReport func(report *diff.Report, w io.Writer) error
+	switch cli.String("format") {
+	case "text":
+		printReport = func(report *diff.Report, w io.Writer) error {
+			return printDiff(report, view, w)
+		}
+	case "json":
+		printReport = printDiffJSON
+	default:
+		return failed.Because("unknown report format "+cli.String("format"), nil)
+	}
+
+	differ := diff.New(key, cli.StringSlice("ignore"))
+	if err := readStore(cli, cli.Args().Get(0), func(e store.Event) error {
+		differ.Left(e)
+		return nil
+	}); err != nil {
+		return err
+	}
+	if err := readStore(cli, cli.Args().Get(1), func(e store.Event) error {
+		differ.Right(e)
+		return nil
+	}); err != nil {
+		return err
+	}
+	report := differ.Report()
+	if err := printReport(report, cli.Root().Writer); err != nil {
+		return err
+	}
+	if report.LeftUnkeyed > 0 || report.RightUnkeyed > 0 {
+		log.Printf("⚠️ Skipped %s and %s messages without a %s",
+			color.YellowString("%d", report.LeftUnkeyed), color.YellowString("%d", report.RightUnkeyed), cli.String("key"))
+	}
+	if report.Differs() {
+		return differencesFound()
+	}
+	return nil
+}
+
+// differencesFound exits with 1 without a message, as diff does when its inputs differ.
+func differencesFound() error {
+	return cli.Exit("", 1)
+}
+
+func readStore(cli *cli.Command, filename string, fn func(e store.Event) error) error {
+	st, err := openExistingStore(cli, filename)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := st.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}()
+	return st.Events(store.Filter{}, fn)
+}
+
+func printDiff(report *diff.Report, view render.BodyView, w io.Writer) error {
+	for _, m := range report.Missing {
+		_, _ = fmt.Fprintln(w, color.RedString("- %s", m.Key), fmt.Sprintf("#%d %s %s missing", m.ID, m.Exchange, m.RoutingKey))
+	}
+	for _, m := range report.Extra {
+		_, _ = fmt.Fprintln(w, color.GreenString("+ %s", m.Key), fmt.Sprintf("#%d %s %s extra", m.ID, m.Exchange, m.RoutingKey))
+	}
+	for _, c := range report.Changed {
+		_, _ = fmt.Fprintln(w, color.YellowString("~ %s", c.Key), fmt.Sprintf("#%d → #%d", c.LeftID, c.RightID))
+		for _, d := range c.Differences {
+			if d.Field != "body" {
+				_, _ = fmt.Fprintf(w, "    %s: %s → %s\n", d.Field, color.RedString(diffValue(d.Field, d.Left)), color.GreenString(diffValue(d.Field, d.Right)))
+				continue
+			}
+			left, right := diffBody(d.Left, view), diffBody(d.Right, view)
+			if strings.Contains(left+right, "\n") {
+				_, _ = fmt.Fprintf(w, "    body:\n%s\n%s\n", color.RedString(indent("    - ", left)), color.GreenString(indent("    + ", right)))
+			} else {
+				_, _ = fmt.Fprintf(w, "    body: %s → %s\n", color.RedString(left), color.GreenString(right))
+			}
+		}
+	}
+	log.Printf("📊 %s matched, %s changed, %s missing, %s extra",
+		color.GreenString("%d", report.Matched),
+		color.YellowString("%d", len(report.Changed)),
+		color.RedString("%d", len(report.Missing)),
+		color.GreenString("%d", len(report.Extra)))
+	return nil
+}
+
+// diffValue renders a value of a difference, telling absent fields apart from empty ones. Header and body fields are
+// JSON already, other fields are quoted.
+func diffValue(field string, value any) string {
+	if value == nil {
+		return "<absent>"
+	}
+	if strings.HasPrefix(field, "header.") || strings.HasPrefix(field, "body.") {
+		return fmt.Sprint(value)
+	}
+	return fmt.Sprintf("%q", value)
+}
+
+// diffBody renders a body of a difference as printed with --bodies.
+func diffBody(value any, view render.BodyView) string {
+	body, ok := value.(string)
+	if !ok {
+		return diffValue("body", value)
+	}
+	return view.Render([]byte(body))
+}
+
+// indent prefixes the first line of a text, aligning the others with it.
+func indent(prefix string, text string) string {
+	return prefix + strings.ReplaceAll(text, "\n", "\n"+strings.Repeat(" ", len(prefix)))
+}
+
+func printDiffJSON(report *diff.Report, w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
@@ -0,0 +1,267 @@
+package diff
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	failed "github.com/ghokun/coyote/error"
+	"github.com/ghokun/coyote/jsonpath"
+	"github.com/ghokun/coyote/store"
+)
+
+// Key identifies the message an event is compared with in the other store, it reports false for events without a key.
+type Key func(e store.Event) (key string, ok bool)
+
+// ParseKey parses message-id, correlation-id, routing-key or body.<path> for a JSON body field.
+func ParseKey(text string) (Key, error) {
+	switch {
+	case text == "message-id":
+		return func(e store.Event) (string, bool) { return e.MessageId, e.MessageId != "" }, nil
+	case text == "correlation-id":
+		return func(e store.Event) (string, bool) { return e.CorrelationId, e.CorrelationId != "" }, nil
+	case text == "routing-key":
+		return func(e store.Event) (string, bool) { return e.RoutingKey, true }, nil
+	case strings.HasPrefix(text, "body."):
+		path := strings.TrimPrefix(text, "body.")
+		return func(e store.Event) (string, bool) {
+			doc, err := jsonpath.Decode(e.Body)
+			if err != nil {
+				return "", false
+			}
+			value, found := jsonpath.Lookup(doc, path)
+			if !found {
+				return "", false
+			}
+			return jsonpath.String(value), true
+		}, nil
+	default:
+		return nil, failed.Because("unknown key "+text+", expected message-id, correlation-id, routing-key or body.<path>", nil)
+	}
+}
+
+// Message is an event found in only one of the stores.
+type Message struct {
+	Key        string `json:"key"`
+	ID         int64  `json:"id"`
+	Exchange   string `json:"exchange"`
+	RoutingKey string `json:"routing_key"`
+}
+
+// Difference is a field whose values differ, values are nil when the field is absent on one side.
+type Difference struct {
+	Field string `json:"field"`
+	Left  any    `json:"left"`
+	Right any    `json:"right"`
+}
+
+// Change is a message found in both stores with different fields.
+type Change struct {
+	Key         string       `json:"key"`
+	LeftID      int64        `json:"left_id"`
+	RightID     int64        `json:"right_id"`
+	Differences []Difference `json:"differences"`
+}
+
+// Report tells how the right store differs from the left one.
+type Report struct {
+	Matched      int       `json:"matched"`
+	LeftUnkeyed  int       `json:"left_unkeyed"`
+	RightUnkeyed int       `json:"right_unkeyed"`
+	Missing      []Message `json:"missing"`
+	Extra        []Message `json:"extra"`
+	Changed      []Change  `json:"changed"`
+}
+
+// Differs reports whether a message is missing, extra or changed.
+func (r *Report) Differs() bool {
+	return len(r.Missing) > 0 || len(r.Extra) > 0 || len(r.Changed) > 0
+}
+
+// Differ pairs the events of two stores by key and compares them. Events sharing a key are paired in the order
+// they were captured, so keying by routing key pairs the nth message of a routing key on both sides.
+// Events of the left store are held until they are paired, the right store is streamed.
+type Differ struct {
+	key    Key
+	ignore []string
+	left   map[string][]store.Event
+	report Report
+}
+
+// New creates a differ ignoring the given fields, as named in differences. Ignoring a field ignores the fields nested in it.
+func New(key Key, ignore []string) *Differ {
+	return &Differ{key: key, ignore: ignore, left: map[string][]store.Event{}}
+}
+
+func (d *Differ) Left(e store.Event) {
+	key, ok := d.key(e)
+	if !ok {
+		d.report.LeftUnkeyed++
+		return
+	}
+	d.left[key] = append(d.left[key], e)
+}
+
+// Right pairs the event with the first unpaired left event of its key, all left events must have been added before.
+func (d *Differ) Right(e store.Event) {
+	key, ok := d.key(e)
+	if !ok {
+		d.report.RightUnkeyed++
+		return
+	}
+	waiting := d.left[key]
+	if len(waiting) == 0 {
+		d.report.Extra = append(d.report.Extra, message(key, e))
+		return
+	}
+	left := waiting[0]
+	if len(waiting) == 1 {
+		delete(d.left, key)
+	} else {
+		d.left[key] = waiting[1:]
+	}
+	differences := d.compare(left, e)
+	if len(differences) == 0 {
+		d.report.Matched++
+		return
+	}
+	d.report.Changed = append(d.report.Changed, Change{Key: key, LeftID: left.ID, RightID: e.ID, Differences: differences})
+}
+
+// Report reports the left events that were not paired as missing, in the order they were captured.
+func (d *Differ) Report() *Report {
+	report := d.report
+	report.Missing = []Message{}
+	if report.Extra == nil {
+		report.Extra = []Message{}
+	}
+	if report.Changed == nil {
+		report.Changed = []Change{}
+	}
+	for key, events := range d.left {
+		for _, e := range events {
+			report.Missing = append(report.Missing, message(key, e))
+		}
+	}
+	slices.SortFunc(report.Missing, func(a, b Message) int { return cmp.Compare(a.ID, b.ID) })
+	return &report
+}
+
+func message(key string, e store.Event) Message {
+	return Message{Key: key, ID: e.ID, Exchange: e.Exchange, RoutingKey: e.RoutingKey}
+}
+
+func (d *Differ) ignored(field string) bool {
+	for _, ignore := range d.ignore {
+		if field == ignore || strings.HasPrefix(field, ignore+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// compare compares the envelope, properties, headers and body of two events. Headers are compared by name unless
+// a store was written by an older release, and JSON bodies field by field.
+func (d *Differ) compare(left, right store.Event) (differences []Difference) {
+	add := func(field string, l, r any, present bool) {
+		if present && !d.ignored(field) {
+			differences = append(differences, Difference{Field: field, Left: l, Right: r})
+		}
+	}
+	add("exchange", left.Exchange, right.Exchange, left.Exchange != right.Exchange)
+	add("routing-key", left.RoutingKey, right.RoutingKey, left.RoutingKey != right.RoutingKey)
+	add("message-id", left.MessageId, right.MessageId, left.MessageId != right.MessageId)
+	add("correlation-id", left.CorrelationId, right.CorrelationId, left.CorrelationId != right.CorrelationId)
+	add("reply-to", left.ReplyTo, right.ReplyTo, left.ReplyTo != right.ReplyTo)
+
+	leftHeaders, leftOk := left.HeaderTable()
+	rightHeaders, rightOk := right.HeaderTable()
+	if leftOk && rightOk {
+		leftFields, rightFields := flatten("header", map[string]any(leftHeaders)), flatten("header", map[string]any(rightHeaders))
+		// Messages without headers are no different from messages with an empty header table.
+		delete(leftFields, "header")
+		delete(rightFields, "header")
+		for _, field := range compareFields(leftFields, rightFields) {
+			add(field.Field, field.Left, field.Right, true)
+		}
+	} else {
+		add("headers", left.Headers, right.Headers, left.Headers != right.Headers)
+	}
+
+	leftBody, leftErr := jsonpath.Decode(left.Body)
+	rightBody, rightErr := jsonpath.Decode(right.Body)
+	if leftErr == nil && rightErr == nil {
+		for _, field := range compareFields(flatten("body", leftBody), flatten("body", rightBody)) {
+			add(field.Field, field.Left, field.Right, true)
+		}
+	} else {
+		add("body", string(left.Body), string(right.Body), !bytes.Equal(left.Body, right.Body))
+	}
+	return differences
+}
+
+// flatten maps the leaves of a document, encoded as JSON, to their paths below the prefix. Empty objects and arrays
+// are leaves.
+func flatten(prefix string, doc any) map[string]string {
+	fields := map[string]string{}
+	var walk func(path string, value any)
+	walk = func(path string, value any) {
+		switch node := value.(type) {
+		case map[string]any:
+			if len(node) == 0 {
+				fields[path] = "{}"
+			}
+			for name, child := range node {
+				walk(path+"."+name, child)
+			}
+		case []any:
+			if len(node) == 0 {
+				fields[path] = "[]"
+			}
+			for i, child := range node {
+				walk(path+"."+strconv.Itoa(i), child)
+			}
+		default:
+			// Leaves are compared as JSON, so that a change of type such as from "42" to 42 is a difference.
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				encoded = []byte(fmt.Sprint(value))
+			}
+			fields[path] = string(encoded)
+		}
+	}
+	walk(prefix, doc)
+	return fields
+}
+
+// compareFields returns the fields whose values differ in the order of their paths.
+func compareFields(left, right map[string]string) (differences []Difference) {
+	paths := slices.Sorted(maps.Keys(left))
+	for path := range right {
+		if _, found := left[path]; !found {
+			paths = append(paths, path)
+		}
+	}
+	slices.Sort(paths)
+	for _, path := range paths {
+		l, inLeft := left[path]
+		r, inRight := right[path]
+		if inLeft && inRight && l == r {
+			continue
+		}
+		difference := Difference{Field: path}
+		if inLeft {
+			difference.Left = l
+		}
+		if inRight {
+			difference.Right = r
+		}
+		differences = append(differences, difference)
+	}
+	return differences
+}
@@ -0,0 +1,110 @@
+package diff
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ghokun/coyote/store"
+)
+
+func TestParseKey(t *testing.T) {
+	e := store.Event{RoutingKey: "order.created", MessageId: "m1", Body: []byte(`{"order":{"id":42}}`)}
+	tests := []struct {
+		key      string
+		expected string
+		ok       bool
+	}{
+		{"message-id", "m1", true},
+		{"correlation-id", "", false},
+		{"routing-key", "order.created", true},
+		{"body.order.id", "42", true},
+		{"body.order.total", "", false},
+	}
+	for _, test := range tests {
+		key, err := ParseKey(test.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual, ok := key(e); actual != test.expected || ok != test.ok {
+			t.Errorf("key %s: expected %q %t, got %q %t", test.key, test.expected, test.ok, actual, ok)
+		}
+	}
+	if _, err := ParseKey("timestamp"); err == nil {
+		t.Error("expected an error for an unknown key")
+	}
+}
+
+func TestDiffer(t *testing.T) {
+	key, _ := ParseKey("routing-key")
+	differ := New(key, []string{"header.ts", "body.order.meta"})
+	for _, e := range []store.Event{
+		{ID: 1, RoutingKey: "order.created", Headers: `{"ts":1}`, Body: []byte(`{"order":{"id":1,"meta":{"at":1}}}`)},
+		{ID: 2, RoutingKey: "order.created", Headers: `{"tenant":"a"}`, Body: []byte(`{"order":{"id":2,"items":[1,2]}}`)},
+		{ID: 3, RoutingKey: "order.paid", Headers: "map[]", Body: []byte("paid")},
+		{ID: 4, RoutingKey: "order.shipped", Headers: "{}"},
+	} {
+		differ.Left(e)
+	}
+	for _, e := range []store.Event{
+		{ID: 1, RoutingKey: "order.created", Headers: `{"ts":2}`, Body: []byte(`{"order":{"id":1,"meta":{"at":2}}}`)},
+		{ID: 2, RoutingKey: "order.created", Body: []byte(`{"order":{"id":2,"items":[1]}}`)},
+		{ID: 3, RoutingKey: "order.paid", Headers: "{}", Body: []byte("paid twice")},
+		{ID: 4, RoutingKey: "order.refunded", Headers: "{}"},
+	} {
+		differ.Right(e)
+	}
+	report := differ.Report()
+
+	expected := &Report{
+		Matched: 1,
+		Missing: []Message{{Key: "order.shipped", ID: 4, RoutingKey: "order.shipped"}},
+		Extra:   []Message{{Key: "order.refunded", ID: 4, RoutingKey: "order.refunded"}},
+		Changed: []Change{
+			{Key: "order.created", LeftID: 2, RightID: 2, Differences: []Difference{
+				{Field: "headers", Left: `{"tenant":"a"}`, Right: ""},
+				{Field: "body.order.items.1", Left: "2", Right: nil},
+			}},
+			{Key: "order.paid", LeftID: 3, RightID: 3, Differences: []Difference{
+				{Field: "headers", Left: "map[]", Right: "{}"},
+				{Field: "body", Left: "paid", Right: "paid twice"},
+			}},
+		},
+	}
+	if !reflect.DeepEqual(report, expected) {
+		t.Errorf("expected %+v, got %+v", expected, report)
+	}
+	if !report.Differs() {
+		t.Error("expected the report to differ")
+	}
+}
+
+func TestCompareHeaders(t *testing.T) {
+	differ := New(nil, nil)
+	differences := differ.compare(
+		store.Event{Headers: `{"tenant":"a","trace":{"id":1}}`},
+		store.Event{Headers: `{"tenant":"b","retry":true}`})
+	expected := []Difference{
+		{Field: "header.retry", Left: nil, Right: "true"},
+		{Field: "header.tenant", Left: `"a"`, Right: `"b"`},
+		{Field: "header.trace.id", Left: "1", Right: nil},
+	}
+	if !reflect.DeepEqual(differences, expected) {
+		t.Errorf("expected %+v, got %+v", expected, differences)
+	}
+	if differences := differ.compare(store.Event{Headers: "{}"}, store.Event{Headers: "{}"}); len(differences) != 0 {
+		t.Errorf("expected no differences, got %+v", differences)
+	}
+}
+
+func TestCompareTypes(t *testing.T) {
+	differences := New(nil, nil).compare(
+		store.Event{Body: []byte(`{"id":"42","paid":"true","total":1.50,"tags":["a"]}`)},
+		store.Event{Body: []byte(`{"id":42,"paid":true,"total":1.50,"tags":["a"]}`)})
+	expected := []Difference{
+		{Field: "body.id", Left: `"42"`, Right: "42"},
+		{Field: "body.paid", Left: `"true"`, Right: "true"},
+	}
+	if !reflect.DeepEqual(differences, expected) {
+		t.Errorf("expected changes of type to differ, got %+v", differences)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strings"
+
+	"github.com/cqroot/prompt"
+	"github.com/cqroot/prompt/input"
+	"github.com/fatih/color"
+	failed "github.com/ghokun/coyote/error"
+	"github.com/ghokun/coyote/store"
+	"github.com/urfave/cli/v3"
+)
+
+// promptedKey keeps the passphrase entered for --key-prompt, so that commands reading several stores ask only once.
+var promptedKey []byte
+
+// encryptionKey returns the secret given with --key-file, --key-env or --key-prompt, or nil when none is given.
+func encryptionKey(cli *cli.Command) (secret []byte, err error) {
+	switch {
+	case cli.IsSet("key-file"):
+		content, err := os.ReadFile(cli.String("key-file"))
+		if err != nil {
+			return nil, failed.Because("failed to read key file", err)
+		}
+		secret = []byte(strings.TrimRight(string(content), "\r\n"))
+	case cli.IsSet("key-env"):
+		secret = []byte(os.Getenv(cli.String("key-env")))
+		if len(secret) == 0 {
+			return nil, failed.Because("environment variable "+cli.String("key-env")+" is not set", nil)
+		}
+	case cli.Bool("key-prompt"):
+		if promptedKey == nil {
+			passphrase, err := prompt.New().Ask("Passphrase").Input("", input.WithCharLimit(0), input.WithEchoMode(input.EchoPassword))
+			if err != nil {
+				return nil, failed.Because("failed to provide passphrase", err)
+			}
+			promptedKey = []byte(passphrase)
+		}
+		secret = promptedKey
+	default:
+		return nil, nil
+	}
+	if len(secret) == 0 {
+		return nil, failed.Because("encryption key is empty", nil)
+	}
+	return secret, nil
+}
+
+// openStore opens a store for writing. Given a key, new stores are encrypted with it and encrypted ones unlocked.
+func openStore(cli *cli.Command, filename string) (*store.Store, error) {
+	st, err := store.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	return unlockStore(cli, st, filename)
+}
+
+// openExistingStore opens a store for reading, decrypting its events with the key when it is encrypted.
+func openExistingStore(cli *cli.Command, filename string) (*store.Store, error) {
+	st, err := store.OpenExisting(filename)
+	if err != nil {
+		return nil, err
+	}
+	return unlockStore(cli, st, filename)
+}
+
+func unlockStore(cli *cli.Command, st *store.Store, filename string) (*store.Store, error) {
+	secret, err := encryptionKey(cli)
+	if err == nil && secret == nil && st.Encrypted() {
+		err = failed.Because("store "+filename+" is encrypted, its key is required with --key-file, --key-env or --key-prompt", nil)
+	}
+	if err == nil && secret != nil {
+		encrypted := st.Encrypted()
+		if err = st.Unlock(secret); err == nil && !encrypted && st.Encrypted() {
+			log.Printf("🔒 Encrypting headers and bodies in %s", color.YellowString(filename))
+		}
+	}
+	if err != nil {
+		if err := st.Close(); err != nil {
+			log.Println("⚠️ Failed to close store:", err)
+		}
+		return nil, failed.Because("failed to open store "+filename, err)
+	}
+	return st, nil
+}
@@ -0,0 +1,246 @@
+package encryption
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+
+	failed "github.com/ghokun/coyote/error"
+)
+
+const (
+	// SaltSize is the size of the random salt keys are derived with.
+	SaltSize = 16
+	// Iterations of PBKDF2 deriving keys from secrets, which may be passphrases.
+	Iterations = 600_000
+	keySize    = 32
+)
+
+// ErrWrongKey is returned when data was encrypted with another key or was tampered with.
+var ErrWrongKey = errors.New("wrong key or corrupted data")
+
+// Key encrypts and authenticates data with AES-256-GCM.
+type Key struct {
+	aead cipher.AEAD
+}
+
+// NewSalt returns a random salt to derive a key with.
+func NewSalt() ([]byte, error) {
+	salt := make([]byte, SaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, failed.Because("failed to generate salt", err)
+	}
+	return salt, nil
+}
+
+// Derive derives a key from a secret read from a key file, an environment variable or a passphrase prompt.
+func Derive(secret []byte, salt []byte, iterations int) (*Key, error) {
+	if len(secret) == 0 {
+		return nil, failed.Because("encryption key is empty", nil)
+	}
+	derived, err := pbkdf2.Key(sha256.New, string(secret), salt, iterations, keySize)
+	if err != nil {
+		return nil, failed.Because("failed to derive encryption key", err)
+	}
+	block, err := aes.NewCipher(derived)
+	if err != nil {
+		return nil, failed.Because("failed to create cipher", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, failed.Because("failed to create cipher", err)
+	}
+	return &Key{aead: aead}, nil
+}
+
+// Seal encrypts the plaintext with a random nonce, which is prepended to the ciphertext. The context is authenticated
+// but not encrypted, so that a ciphertext cannot be passed off as one sealed for another purpose.
+func (k *Key) Seal(plaintext []byte, context string) ([]byte, error) {
+	nonce := make([]byte, k.aead.NonceSize(), k.aead.NonceSize()+len(plaintext)+k.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, failed.Because("failed to generate nonce", err)
+	}
+	return k.aead.Seal(nonce, nonce, plaintext, []byte(context)), nil
+}
+
+// Open decrypts what Seal encrypted with the same context.
+func (k *Key) Open(sealed []byte, context string) ([]byte, error) {
+	if len(sealed) < k.aead.NonceSize() {
+		return nil, ErrWrongKey
+	}
+	plaintext, err := k.aead.Open(nil, sealed[:k.aead.NonceSize()], sealed[k.aead.NonceSize():], []byte(context))
+	if err != nil {
+		return nil, ErrWrongKey
+	}
+	return plaintext, nil
+}
+
+// Encrypted files start with magic, followed by the salt of their key and a random nonce prefix. Their content is split
+// into segments, each sealed with a nonce made of the prefix, the segment index and whether it is the last segment,
+// so that reordered, dropped or truncated segments are detected.
+const (
+	magic       = "COYOTE\x00\x01"
+	prefixSize  = 4
+	segmentSize = 64 * 1024
+	// maxSegments keeps segment indexes within the 4 bytes of the nonce they take, no nonce being used twice.
+	maxSegments = math.MaxUint32 + 1
+)
+
+// IsEncrypted reports whether the reader starts like an encrypted file, without consuming it.
+func IsEncrypted(r *bufio.Reader) bool {
+	start, err := r.Peek(len(magic))
+	return err == nil && string(start) == magic
+}
+
+type writer struct {
+	w      io.Writer
+	key    *Key
+	prefix []byte
+	index  uint64
+	buffer []byte
+}
+
+// NewWriter encrypts what is written to it into w, with a key derived from the secret. Close writes the last segment,
+// without closing w.
+func NewWriter(w io.Writer, secret []byte) (io.WriteCloser, error) {
+	salt, err := NewSalt()
+	if err != nil {
+		return nil, err
+	}
+	key, err := Derive(secret, salt, Iterations)
+	if err != nil {
+		return nil, err
+	}
+	prefix := make([]byte, prefixSize)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, failed.Because("failed to generate nonce", err)
+	}
+	header := append(append([]byte(magic), salt...), prefix...)
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &writer{w: w, key: key, prefix: prefix, buffer: make([]byte, 0, segmentSize)}, nil
+}
+
+func (w *writer) Write(p []byte) (n int, err error) {
+	for len(p) > 0 {
+		if len(w.buffer) == segmentSize {
+			if err := w.seal(false); err != nil {
+				return n, err
+			}
+		}
+		free := min(segmentSize-len(w.buffer), len(p))
+		w.buffer = append(w.buffer, p[:free]...)
+		p, n = p[free:], n+free
+	}
+	return n, nil
+}
+
+func (w *writer) Close() error {
+	return w.seal(true)
+}
+
+func (w *writer) seal(last bool) error {
+	if w.index >= maxSegments {
+		return failed.Because("file is too large to encrypt", nil)
+	}
+	sealed := w.key.aead.Seal(nil, segmentNonce(w.prefix, w.index, last), w.buffer, nil)
+	length := binary.BigEndian.AppendUint32(nil, uint32(len(sealed)))
+	if _, err := w.w.Write(append(length, sealed...)); err != nil {
+		return err
+	}
+	w.index++
+	w.buffer = w.buffer[:0]
+	return nil
+}
+
+// segmentNonce returns the 12 byte nonce of a segment: the 4 byte prefix, 3 zero bytes, the 4 byte big-endian index
+// and 1 when it is the last segment, 0 otherwise.
+func segmentNonce(prefix []byte, index uint64, last bool) []byte {
+	nonce := append(bytes.Clone(prefix), 0, 0, 0)
+	nonce = binary.BigEndian.AppendUint32(nonce, uint32(index))
+	if last {
+		return append(nonce, 1)
+	}
+	return append(nonce, 0)
+}
+
+type reader struct {
+	r       io.Reader
+	key     *Key
+	prefix  []byte
+	index   uint64
+	segment []byte
+	done    bool
+}
+
+// NewReader decrypts a file written by NewWriter with the same secret.
+func NewReader(r io.Reader, secret []byte) (io.Reader, error) {
+	header := make([]byte, len(magic)+SaltSize+prefixSize)
+	if _, err := io.ReadFull(r, header); err != nil || string(header[:len(magic)]) != magic {
+		return nil, failed.Because("not an encrypted file", err)
+	}
+	key, err := Derive(secret, header[len(magic):len(magic)+SaltSize], Iterations)
+	if err != nil {
+		return nil, err
+	}
+	return &reader{r: r, key: key, prefix: header[len(magic)+SaltSize:]}, nil
+}
+
+func (r *reader) Read(p []byte) (n int, err error) {
+	for len(r.segment) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	n = copy(p, r.segment)
+	r.segment = r.segment[n:]
+	return n, nil
+}
+
+func (r *reader) open() error {
+	length := make([]byte, 4)
+	if _, err := io.ReadFull(r.r, length); err != nil {
+		if err == io.EOF {
+			return failed.Because("encrypted file is truncated", nil)
+		}
+		return err
+	}
+	if r.index >= maxSegments {
+		return ErrWrongKey
+	}
+	size := binary.BigEndian.Uint32(length)
+	if size > segmentSize+uint32(r.key.aead.Overhead()) {
+		return ErrWrongKey
+	}
+	sealed := make([]byte, size)
+	if _, err := io.ReadFull(r.r, sealed); err != nil {
+		return failed.Because("encrypted file is truncated", nil)
+	}
+	// A segment only opens with the nonce it was sealed with, which tells whether it is the last one.
+	segment, err := r.key.aead.Open(nil, segmentNonce(r.prefix, r.index, false), sealed, nil)
+	if err != nil {
+		if segment, err = r.key.aead.Open(nil, segmentNonce(r.prefix, r.index, true), sealed, nil); err != nil {
+			return ErrWrongKey
+		}
+		// Nothing is authenticated after the last segment, so that anything appended to the file is rejected.
+		if n, _ := io.ReadFull(r.r, make([]byte, 1)); n > 0 {
+			return failed.Because("encrypted file has data after its last segment", nil)
+		}
+		r.done = true
+	}
+	r.index++
+	r.segment = segment
+	return nil
+}
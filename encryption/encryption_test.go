@@ -0,0 +1,116 @@
+package encryption
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math"
+	"testing"
+)
+
+func TestSeal(t *testing.T) {
+	salt, err := NewSalt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := Derive([]byte("secret"), salt, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := key.Seal([]byte("body"), "body")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plaintext, err := key.Open(sealed, "body"); err != nil || string(plaintext) != "body" {
+		t.Errorf("expected body, got %q, %v", plaintext, err)
+	}
+	if _, err := key.Open(sealed, "headers"); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("expected a value sealed for another context to fail, got %v", err)
+	}
+	other, err := Derive([]byte("other"), salt, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Open(sealed, "body"); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("expected a wrong key to fail, got %v", err)
+	}
+	if _, err := Derive(nil, salt, 1000); err == nil {
+		t.Error("expected an empty secret to fail")
+	}
+}
+
+func TestStream(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), segmentSize/8+3)
+	var encrypted bytes.Buffer
+	w, err := NewWriter(&encrypted, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(bufio.NewReader(bytes.NewReader(encrypted.Bytes()))) {
+		t.Error("expected the file to be told encrypted")
+	}
+	if IsEncrypted(bufio.NewReader(bytes.NewReader(content))) {
+		t.Error("expected plain content not to be told encrypted")
+	}
+
+	decrypted, err := read(encrypted.Bytes(), "secret")
+	if err != nil || !bytes.Equal(decrypted, content) {
+		t.Errorf("expected the content back, got %d bytes, %v", len(decrypted), err)
+	}
+	if _, err := read(encrypted.Bytes(), "other"); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("expected a wrong key to fail, got %v", err)
+	}
+	// Dropping the last segment leaves a file that would otherwise decrypt cleanly.
+	last := len(magic) + SaltSize + prefixSize + 2*(4+segmentSize+16)
+	if _, err := read(encrypted.Bytes()[:last], "secret"); err == nil {
+		t.Error("expected a truncated file to fail")
+	}
+	// Swapping the first two segments keeps every segment intact, only their order changes.
+	start, size := len(magic)+SaltSize+prefixSize, 4+segmentSize+16
+	swapped := bytes.Clone(encrypted.Bytes())
+	copy(swapped[start:], encrypted.Bytes()[start+size:start+2*size])
+	copy(swapped[start+size:], encrypted.Bytes()[start:start+size])
+	if _, err := read(swapped, "secret"); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("expected reordered segments to fail, got %v", err)
+	}
+	// Data appended after the last segment is not authenticated by it.
+	if _, err := read(append(bytes.Clone(encrypted.Bytes()), "trailing"...), "secret"); err == nil {
+		t.Error("expected data after the last segment to fail")
+	}
+}
+
+func TestSegmentNonce(t *testing.T) {
+	prefix := []byte{1, 2, 3, 4}
+	seen := map[string]uint64{}
+	for _, index := range []uint64{0, 1, 2, 255, 256, 65536, math.MaxUint32} {
+		for _, last := range []bool{false, true} {
+			nonce := segmentNonce(prefix, index, last)
+			if len(nonce) != 12 {
+				t.Fatalf("expected a 12 byte nonce, got %x", nonce)
+			}
+			if other, found := seen[string(nonce)]; found {
+				t.Errorf("expected segments %d and %d to have different nonces, both got %x", other, index, nonce)
+			}
+			seen[string(nonce)] = index
+		}
+	}
+	if nonce := segmentNonce(prefix, 256, true); hex.EncodeToString(nonce) != "010203040000000000010001" {
+		t.Errorf("expected the prefix, index 256 and the last flag, got %x", nonce)
+	}
+}
+
+func read(encrypted []byte, secret string) ([]byte, error) {
+	r, err := NewReader(bytes.NewReader(encrypted), []byte(secret))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"crypto/sha256"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fatih/color"
+	failed "github.com/ghokun/coyote/error"
+	"github.com/ghokun/coyote/hook"
+	"github.com/ghokun/coyote/metrics"
+	"github.com/ghokun/coyote/store"
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/urfave/cli/v3"
+)
+
+// execBackoff is the delay before the first retry of a failed --exec command, doubling with every retry.
+const execBackoff = time.Second
+
+// Policies for messages whose --exec command still fails after its retries.
+const (
+	execSkip    = "skip"
+	execRequeue = "requeue"
+	execStop    = "stop"
+)
+
+// execSink pipes captured messages to the command given with --exec. Interceptor queues are then consumed with manual
+// acknowledgements: messages are acknowledged once their command succeeded, and rejected according to the policy when it
+// failed. Messages that are not acknowledged when the capture stops are returned to the queue by the broker.
+type execSink struct {
+	runner   *hook.Runner
+	policy   string
+	failures atomic.Int64
+	// redeliveries counts how many times messages returned to the queue were redelivered, by redeliveryKey, until
+	// they are acknowledged or rejected. Messages run again more than maxRedeliveries times are rejected.
+	redeliveries    map[[sha256.Size]byte]int
+	maxRedeliveries int
+	mu              sync.Mutex
+	// expiry ticks when incomplete batches are checked for --exec-batch-timeout, nil when they wait to be complete.
+	expiry *time.Ticker
+}
+
+// newExecSink returns the sink of the command given with --exec, nil when there is none. Failures stop the capture
+// through stopped under the stop policy.
+func newExecSink(cli *cli.Command, stopped chan<- stopReason) (*execSink, error) {
+	if !cli.IsSet("exec") {
+		return nil, nil
+	}
+	s := &execSink{policy: cli.String("exec-on-failure"), redeliveries: map[[sha256.Size]byte]int{}, maxRedeliveries: cli.Int("exec-redeliveries")}
+	if s.policy != execSkip && s.policy != execRequeue && s.policy != execStop {
+		return nil, failed.Because("exec-on-failure must be skip, requeue or stop: "+s.policy, nil)
+	}
+	if s.maxRedeliveries < 0 {
+		return nil, failed.Because("exec-redeliveries must not be negative", nil)
+	}
+	runner, err := hook.New(hook.Options{
+		Command:      cli.String("exec"),
+		Batch:        cli.Int("exec-batch"),
+		BatchTimeout: cli.Duration("exec-batch-timeout"),
+		Concurrency:  cli.Int("exec-concurrency"),
+		Timeout:      cli.Duration("exec-timeout"),
+		Retries:      cli.Int("exec-retries"),
+		Backoff:      execBackoff,
+	}, func(events []store.Event, err error) {
+		s.failures.Add(1)
+		metrics.ExecFailures.Inc()
+		log.Printf("⚠️ Failed to exec %s messages from %s: %v", color.RedString("%d", len(events)), color.YellowString(events[0].RoutingKey), err)
+		if s.policy == execStop {
+			s.runner.Halt()
+			select {
+			case stopped <- stopExecFailed:
+			default:
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.runner = runner
+	// Batches are checked twice per timeout, so that none waits more than half a timeout longer.
+	if cli.Int("exec-batch") > 1 && cli.Duration("exec-batch-timeout") > 0 {
+		s.expiry = time.NewTicker(max(cli.Duration("exec-batch-timeout")/2, time.Millisecond))
+	}
+	log.Printf("🪝 Running %s for every %s messages, %s at once", color.YellowString(cli.String("exec")),
+		color.YellowString("%d", cli.Int("exec-batch")), color.YellowString("%d", cli.Int("exec-concurrency")))
+	return s, nil
+}
+
+// execPrefetch is the number of unacknowledged messages interceptors are delivered when --exec is set: enough for every
+// running command and the batch filling up meanwhile.
+func execPrefetch(cli *cli.Command) int {
+	return min(cli.Int("exec-batch")*(cli.Int("exec-concurrency")+1), 65535)
+}
+
+// send pipes a captured message to the command, settling its delivery once the command ran. Redelivered messages are
+// rejected instead once they were run again as many times as allowed, so that a message the command always fails on
+// is not requeued forever.
+func (s *execSink) send(c captured, redactions []string, validation *store.Validation) {
+	if s == nil {
+		return
+	}
+	key := redeliveryKey(c.Delivery)
+	if c.Redelivered && !s.redeliver(key) {
+		log.Printf("⚠️ Rejecting message from %s redelivered more than %s times", color.YellowString(c.RoutingKey), color.RedString("%d", s.maxRedeliveries))
+		if err := c.Nack(false, false); err != nil {
+			log.Println("⚠️ Failed to reject message:", err)
+		}
+		return
+	}
+	s.runner.Send(capturedEvent(c, redactions, validation), func(err error) {
+		s.settle(c.Delivery, key, err)
+	})
+}
+
+// redeliver counts a redelivery of a message and reports whether it may run the command again.
+func (s *execSink) redeliver(key [sha256.Size]byte) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.redeliveries[key]++
+	if s.redeliveries[key] <= s.maxRedeliveries {
+		return true
+	}
+	delete(s.redeliveries, key)
+	return false
+}
+
+func (s *execSink) settle(d amqp091.Delivery, key [sha256.Size]byte, err error) {
+	requeued := false
+	switch {
+	case err == nil:
+		err = d.Ack(false)
+	case s.policy == execSkip:
+		// Rejected messages are dropped, or dead-lettered when the queue has a dead letter exchange.
+		err = d.Nack(false, false)
+	default:
+		requeued = true
+		err = d.Nack(false, true)
+	}
+	if !requeued {
+		s.mu.Lock()
+		delete(s.redeliveries, key)
+		s.mu.Unlock()
+	}
+	if err != nil {
+		log.Println("⚠️ Failed to settle message:", err)
+	}
+}
+
+// redeliveryKey identifies a message across its redeliveries, for which the broker keeps no identifier. Messages
+// alike in their exchange, routing key, id and body share their redeliveries.
+func redeliveryKey(d amqp091.Delivery) [sha256.Size]byte {
+	h := sha256.New()
+	for _, part := range []string{d.Exchange, d.RoutingKey, d.MessageId} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	h.Write(d.Body)
+	return [sha256.Size]byte(h.Sum(nil))
+}
+
+// pass acknowledges a message that is not piped to the command, e.g. skipped by sampling, so that it is not redelivered.
+func (s *execSink) pass(d amqp091.Delivery) {
+	if s == nil {
+		return
+	}
+	if err := d.Ack(false); err != nil {
+		log.Println("⚠️ Failed to acknowledge message:", err)
+	}
+}
+
+// expiries returns the ticks incomplete batches are checked on, nil when they are not.
+func (s *execSink) expiries() <-chan time.Time {
+	if s == nil || s.expiry == nil {
+		return nil
+	}
+	return s.expiry.C
+}
+
+// expire runs the command with an incomplete batch that waited for --exec-batch-timeout.
+func (s *execSink) expire(now time.Time) {
+	if s != nil {
+		s.runner.Expire(now)
+	}
+}
+
+// halted reports whether the command stopped running under the stop policy. Deliveries are then left unacknowledged.
+func (s *execSink) halted() bool {
+	return s != nil && s.runner.Halted()
+}
+
+// close waits for running commands and reports how many failed.
+func (s *execSink) close() {
+	if s == nil {
+		return
+	}
+	if s.expiry != nil {
+		s.expiry.Stop()
+	}
+	s.runner.Close()
+	log.Printf("🪝 %s exec commands failed", color.RedString("%d", s.failures.Load()))
+}
+
+// capturedEvent returns a captured delivery as the event it is stored as.
+func capturedEvent(c captured, redactions []string, validation *store.Validation) store.Event {
+	return store.Event{
+		Timestamp:     time.Now(),
+		Source:        c.origin.Source,
+		Vhost:         c.origin.Vhost,
+		Exchange:      c.Exchange,
+		RoutingKey:    c.RoutingKey,
+		MessageId:     c.MessageId,
+		CorrelationId: c.CorrelationId,
+		ReplyTo:       c.ReplyTo,
+		ContentType:   c.ContentType,
+		AppId:         c.AppId,
+		UserId:        c.UserId,
+		Headers:       store.EncodeHeaders(c.Headers),
+		Body:          c.Body,
+		Redactions:    redactions,
+		Validation:    validation,
+	}
+}
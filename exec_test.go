@@ -0,0 +1,21 @@
+package main
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+func TestRedeliver(t *testing.T) {
+	s := &execSink{redeliveries: map[[sha256.Size]byte]int{}, maxRedeliveries: 2}
+	paid := redeliveryKey(amqp091.Delivery{Exchange: "orders", RoutingKey: "order.paid", Body: []byte(`{"id":1}`)})
+	if created := redeliveryKey(amqp091.Delivery{Exchange: "orders", RoutingKey: "order.created", Body: []byte(`{"id":1}`)}); created == paid {
+		t.Error("expected messages with other routing keys to be told apart")
+	}
+	for i, expected := range []bool{true, true, false, true} {
+		if redeliver := s.redeliver(paid); redeliver != expected {
+			t.Errorf("expected redelivery %d to run the command %t, got %t", i+1, expected, redeliver)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/ghokun/coyote/encryption"
+	failed "github.com/ghokun/coyote/error"
+	"github.com/ghokun/coyote/export"
+	"github.com/ghokun/coyote/predicate"
+	"github.com/ghokun/coyote/store"
+	"github.com/urfave/cli/v3"
+)
+
+const exportUsage = `coyote export [options]
+
+Exports the events of a store as JSON lines, CSV, Parquet or an HTTP Archive (HAR) for other tools.
+Events are streamed from the store, so that stores larger than memory can be exported.
+
+Examples:
+# Export every event as JSON lines to standard output
+coyote export --store events.sqlite
+
+# Export order events of the first capture session since noon as CSV without bodies
+coyote export --store events.sqlite --format csv --csv-body none --session 1 --since '2026-01-02 12:00:00' \
+  --where 'routing-key~^order\.' --output orders.csv
+
+# Export everything as Parquet for analysis
+coyote export --store events.sqlite --format parquet --output events.parquet
+
+# Export an encrypted store into an encrypted file, reading the key from an environment variable
+coyote export --store events.sqlite --key-env COYOTE_KEY --encrypt --output events.jsonl.enc`
+
+func exportCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "export",
+		Usage:     "Exports stored events as JSON lines, CSV, Parquet or HAR.",
+		UsageText: exportUsage,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "store",
+				Usage: "SQLite filename to read events from.",
+			},
+			&cli.StringFlag{
+				Name:  "format",
+				Value: "jsonl",
+				Usage: "Export format, one of jsonl, csv, parquet or har.",
+			},
+			&cli.StringFlag{
+				Name:  "output",
+				Usage: "File to write the export to, standard output if omitted.",
+			},
+			&cli.StringSliceFlag{
+				Name:  "where",
+				Usage: "Exports only events matching one of the given predicates, written as for coyote expect.",
+			},
+			&cli.Int64Flag{
+				Name:  "session",
+				Usage: "Exports only events of the given capture session.",
+			},
+			&cli.TimestampFlag{
+				Name:   "since",
+				Usage:  "Exports only events captured at or after the given time.",
+				Config: exportTimestampConfig,
+			},
+			&cli.TimestampFlag{
+				Name:   "until",
+				Usage:  "Exports only events captured before the given time.",
+				Config: exportTimestampConfig,
+			},
+			&cli.BoolFlag{
+				Name:  "csv-header",
+				Value: true,
+				Usage: "Writes a header row in CSV exports.",
+			},
+			&cli.StringFlag{
+				Name:  "csv-body",
+				Value: export.BodyText,
+				Usage: "Body column of CSV exports, one of text, base64 or none.",
+			},
+			&cli.BoolFlag{
+				Name:  "encrypt",
+				Usage: "Encrypts the export with the key given with --key-file, --key-env or --key-prompt.",
+			},
+		},
+		Action: exportEvents,
+	}
+}
+
+// exportTimestampConfig reads --since and --until in local time, as events are stored.
+var exportTimestampConfig = cli.TimestampConfig{
+	Timezone: time.Local,
+	Layouts:  []string{time.RFC3339, time.DateTime, time.DateOnly},
+}
+
+func exportEvents(_ context.Context, cli *cli.Command) error {
+	if !cli.IsSet("store") {
+		return failed.Because("store must be set", nil)
+	}
+	var predicates []*predicate.Predicate
+	for _, text := range cli.StringSlice("where") {
+		p, err := predicate.Parse(text)
+		if err != nil {
+			return err
+		}
+		predicates = append(predicates, p)
+	}
+
+	// The format, store and key are checked before the output is created, so that failing exports leave no file.
+	var newWriter func(w io.Writer) (export.Writer, error)
+	switch cli.String("format") {
+	case "jsonl":
+		newWriter = func(w io.Writer) (export.Writer, error) { return export.JSONL(w), nil }
+	case "csv":
+		newWriter = func(w io.Writer) (export.Writer, error) {
+			return export.CSV(w, cli.Bool("csv-header"), cli.String("csv-body"))
+		}
+	case "parquet":
+		newWriter = func(w io.Writer) (export.Writer, error) { return export.Parquet(w), nil }
+	case "har":
+		newWriter = func(w io.Writer) (export.Writer, error) { return export.HAR(w, cli.Root().Version), nil }
+	default:
+		return failed.Because("unknown export format "+cli.String("format"), nil)
+	}
+
+	st, err := openExistingStore(cli, cli.String("store"))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := st.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}()
+	var secret []byte
+	if cli.Bool("encrypt") {
+		if secret, err = encryptionKey(cli); err != nil {
+			return err
+		}
+		if secret == nil {
+			return failed.Because("encrypted exports require a key with --key-file, --key-env or --key-prompt", nil)
+		}
+	}
+
+	// Exports failing partway leave no output file behind, and encrypted ones are only sealed once complete, so that
+	// they never decrypt as a complete export.
+	var output io.Writer = cli.Root().Writer
+	var file *os.File
+	discard := func(err error) error {
+		if file != nil {
+			_ = file.Close()
+			_ = os.Remove(file.Name())
+		}
+		return err
+	}
+	if cli.IsSet("output") {
+		if file, err = os.Create(cli.String("output")); err != nil {
+			return failed.Because("failed to create "+cli.String("output"), err)
+		}
+		output = file
+	}
+	var encrypted io.WriteCloser
+	if secret != nil {
+		if encrypted, err = encryption.NewWriter(output, secret); err != nil {
+			return discard(err)
+		}
+		output = encrypted
+	}
+	writer, err := newWriter(output)
+	if err != nil {
+		return discard(err)
+	}
+
+	filter := store.Filter{SessionID: cli.Int64("session"), Since: cli.Timestamp("since"), Until: cli.Timestamp("until")}
+	exported := 0
+	err = st.Events(filter, func(e store.Event) error {
+		if !matchesAny(predicates, e) {
+			return nil
+		}
+		exported++
+		return writer.Write(e)
+	})
+	if err != nil {
+		return discard(failed.Because("failed to export events", err))
+	}
+	if err := writer.Close(); err != nil {
+		return discard(failed.Because("failed to export events", err))
+	}
+	if encrypted != nil {
+		if err := encrypted.Close(); err != nil {
+			return discard(failed.Because("failed to encrypt export", err))
+		}
+	}
+	if file != nil {
+		if err := file.Close(); err != nil {
+			_ = os.Remove(file.Name())
+			return failed.Because("failed to write "+cli.String("output"), err)
+		}
+	}
+	if cli.IsSet("output") {
+		log.Printf("📦 Exported %s events to %s", color.YellowString("%d", exported), color.YellowString(cli.String("output")))
+	}
+	return nil
+}
+
+// matchesAny reports whether the event matches one of the predicates, or whether there are none.
+func matchesAny(predicates []*predicate.Predicate, e store.Event) bool {
+	if len(predicates) == 0 {
+		return true
+	}
+	d := e.Delivery()
+	for _, p := range predicates {
+		if p.Match(d) {
+			return true
+		}
+	}
+	return false
+}
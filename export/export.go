@@ -0,0 +1,423 @@
+package export
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	failed "github.com/ghokun/coyote/error"
+	"github.com/ghokun/coyote/jsonpath"
+	"github.com/ghokun/coyote/store"
+	"github.com/parquet-go/parquet-go"
+)
+
+// Writer writes events one at a time, so that exports of large stores are streamed.
+// Close flushes what is buffered and completes the document, without closing the underlying writer.
+type Writer interface {
+	Write(e store.Event) error
+	Close() error
+}
+
+// Body columns of CSV exports.
+const (
+	BodyText   = "text"
+	BodyBase64 = "base64"
+	BodyNone   = "none"
+)
+
+// body returns the body as text, or base64 encoded with its encoding when it is not valid UTF-8.
+func body(e store.Event) (text string, encoding string) {
+	if utf8.Valid(e.Body) {
+		return string(e.Body), ""
+	}
+	return base64.StdEncoding.EncodeToString(e.Body), "base64"
+}
+
+type record struct {
+	ID            int64     `json:"id"`
+	SessionID     int64     `json:"session_id,omitempty"`
+	Timestamp     time.Time `json:"timestamp"`
+	Source        string    `json:"source,omitempty"`
+	Vhost         string    `json:"vhost,omitempty"`
+	Exchange      string    `json:"exchange"`
+	RoutingKey    string    `json:"routing_key"`
+	MessageId     string    `json:"message_id,omitempty"`
+	CorrelationId string    `json:"correlation_id,omitempty"`
+	ReplyTo       string    `json:"reply_to,omitempty"`
+	ContentType   string    `json:"content_type,omitempty"`
+	AppId         string    `json:"app_id,omitempty"`
+	UserId        string    `json:"user_id,omitempty"`
+	Headers       any       `json:"headers"`
+	Body          string    `json:"body"`
+	BodyEncoding  string    `json:"body_encoding,omitempty"`
+	// Redactions names the redaction rules that fired on the message, omitted when none did.
+	Redactions []string `json:"redactions,omitempty"`
+	// Validation is the result of validating the body against its contract, omitted when none applied.
+	Validation *store.Validation `json:"validation,omitempty"`
+}
+
+type jsonl struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+// JSONL writes an event per line. Headers are a JSON object, or text for stores written by older releases.
+func JSONL(w io.Writer) Writer {
+	buffered := bufio.NewWriter(w)
+	return &jsonl{w: buffered, enc: json.NewEncoder(buffered)}
+}
+
+func (j *jsonl) Write(e store.Event) error {
+	r := record{
+		ID:            e.ID,
+		SessionID:     e.SessionID,
+		Timestamp:     e.Timestamp,
+		Source:        e.Source,
+		Vhost:         e.Vhost,
+		Exchange:      e.Exchange,
+		RoutingKey:    e.RoutingKey,
+		MessageId:     e.MessageId,
+		CorrelationId: e.CorrelationId,
+		ReplyTo:       e.ReplyTo,
+		ContentType:   e.ContentType,
+		AppId:         e.AppId,
+		UserId:        e.UserId,
+		Headers:       e.Headers,
+		Redactions:    e.Redactions,
+		Validation:    e.Validation,
+	}
+	if headers, ok := e.HeaderTable(); ok {
+		r.Headers = headers
+	}
+	r.Body, r.BodyEncoding = body(e)
+	return j.enc.Encode(r)
+}
+
+func (j *jsonl) Close() error {
+	return j.w.Flush()
+}
+
+type csvWriter struct {
+	w    *csv.Writer
+	body string
+}
+
+// CSV writes an event per row, with a header row when asked for. Redactions and validation are JSON, empty when no
+// rule fired or no contract applied. The body column holds the body as text, base64 encoded, or is left out.
+func CSV(w io.Writer, header bool, body string) (Writer, error) {
+	switch body {
+	case BodyText, BodyBase64, BodyNone:
+	default:
+		return nil, failed.Because("unknown body column "+body+", expected text, base64 or none", nil)
+	}
+	c := &csvWriter{w: csv.NewWriter(w), body: body}
+	if header {
+		columns := []string{"id", "session_id", "timestamp", "source", "vhost", "exchange", "routing_key", "message_id", "correlation_id", "reply_to",
+			"content_type", "app_id", "user_id", "headers", "redactions", "validation"}
+		if body != BodyNone {
+			columns = append(columns, "body")
+		}
+		if err := c.w.Write(columns); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (c *csvWriter) Write(e store.Event) error {
+	var redactions, validation []byte
+	var err error
+	if len(e.Redactions) > 0 {
+		if redactions, err = json.Marshal(e.Redactions); err != nil {
+			return err
+		}
+	}
+	if e.Validation != nil {
+		if validation, err = json.Marshal(e.Validation); err != nil {
+			return err
+		}
+	}
+	row := []string{
+		strconv.FormatInt(e.ID, 10),
+		strconv.FormatInt(e.SessionID, 10),
+		e.Timestamp.Format(time.RFC3339Nano),
+		e.Source,
+		e.Vhost,
+		e.Exchange,
+		e.RoutingKey,
+		e.MessageId,
+		e.CorrelationId,
+		e.ReplyTo,
+		e.ContentType,
+		e.AppId,
+		e.UserId,
+		e.Headers,
+		string(redactions),
+		string(validation),
+	}
+	switch c.body {
+	case BodyText:
+		row = append(row, string(e.Body))
+	case BodyBase64:
+		row = append(row, base64.StdEncoding.EncodeToString(e.Body))
+	}
+	return c.w.Write(row)
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type parquetRow struct {
+	ID            int64              `parquet:"id"`
+	SessionID     *int64             `parquet:"session_id,optional"`
+	Timestamp     time.Time          `parquet:"timestamp,timestamp(microsecond)"`
+	Source        string             `parquet:"source"`
+	Vhost         string             `parquet:"vhost"`
+	Exchange      string             `parquet:"exchange"`
+	RoutingKey    string             `parquet:"routing_key"`
+	MessageId     string             `parquet:"message_id"`
+	CorrelationId string             `parquet:"correlation_id"`
+	ReplyTo       string             `parquet:"reply_to"`
+	ContentType   string             `parquet:"content_type"`
+	AppId         string             `parquet:"app_id"`
+	UserId        string             `parquet:"user_id"`
+	Headers       map[string]string  `parquet:"headers"`
+	Body          []byte             `parquet:"body"`
+	Redactions    []string           `parquet:"redactions,list"`
+	Validation    *parquetValidation `parquet:"validation,optional"`
+}
+
+type parquetValidation struct {
+	Contract string   `parquet:"contract"`
+	Valid    bool     `parquet:"valid"`
+	Errors   []string `parquet:"errors,list"`
+}
+
+// parquetBatch is how many rows are buffered before being handed to the parquet writer,
+// and parquetRowGroup bounds the rows held in memory until a row group is flushed.
+const (
+	parquetBatch    = 1024
+	parquetRowGroup = 100_000
+)
+
+type parquetWriter struct {
+	w    *parquet.GenericWriter[parquetRow]
+	rows []parquetRow
+}
+
+// Parquet writes events with a typed schema. Headers are a map of their values as text,
+// which is empty for stores written by older releases. Validation is null when no contract applied.
+func Parquet(w io.Writer) Writer {
+	return &parquetWriter{w: parquet.NewGenericWriter[parquetRow](w, parquet.MaxRowsPerRowGroup(parquetRowGroup))}
+}
+
+func (p *parquetWriter) Write(e store.Event) error {
+	row := parquetRow{
+		ID:            e.ID,
+		Timestamp:     e.Timestamp,
+		Source:        e.Source,
+		Vhost:         e.Vhost,
+		Exchange:      e.Exchange,
+		RoutingKey:    e.RoutingKey,
+		MessageId:     e.MessageId,
+		CorrelationId: e.CorrelationId,
+		ReplyTo:       e.ReplyTo,
+		ContentType:   e.ContentType,
+		AppId:         e.AppId,
+		UserId:        e.UserId,
+		Body:          e.Body,
+		Redactions:    e.Redactions,
+	}
+	if e.SessionID != 0 {
+		row.SessionID = &e.SessionID
+	}
+	if e.Validation != nil {
+		row.Validation = &parquetValidation{Contract: e.Validation.Contract, Valid: e.Validation.Valid, Errors: e.Validation.Errors}
+	}
+	if headers, ok := e.HeaderTable(); ok {
+		row.Headers = make(map[string]string, len(headers))
+		for name, value := range headers {
+			row.Headers[name] = jsonpath.String(value)
+		}
+	}
+	p.rows = append(p.rows, row)
+	if len(p.rows) >= parquetBatch {
+		return p.flush()
+	}
+	return nil
+}
+
+func (p *parquetWriter) flush() error {
+	if _, err := p.w.Write(p.rows); err != nil {
+		return err
+	}
+	p.rows = p.rows[:0]
+	return nil
+}
+
+func (p *parquetWriter) Close() error {
+	if err := p.flush(); err != nil {
+		return err
+	}
+	return p.w.Close()
+}
+
+// HAR entries describe a message as a request publishing it, following HTTP Archive 1.2 so that HAR viewers
+// can open them. AMQP fields without an HTTP counterpart are custom fields prefixed with an underscore.
+type harEntry struct {
+	StartedDateTime time.Time      `json:"startedDateTime"`
+	Time            int            `json:"time"`
+	Request         harRequest     `json:"request"`
+	Response        harResponse    `json:"response"`
+	Cache           struct{}       `json:"cache"`
+	Timings         map[string]int `json:"timings"`
+	ID              int64          `json:"_id"`
+	SessionID       int64          `json:"_sessionId,omitempty"`
+	Source          string         `json:"_source,omitempty"`
+	Vhost           string         `json:"_vhost,omitempty"`
+	Exchange        string         `json:"_exchange"`
+	RoutingKey      string         `json:"_routingKey"`
+	MessageId       string         `json:"_messageId,omitempty"`
+	CorrelationId   string         `json:"_correlationId,omitempty"`
+	ReplyTo         string         `json:"_replyTo,omitempty"`
+}
+
+type harRequest struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []struct{}  `json:"cookies"`
+	Headers     []harHeader `json:"headers"`
+	QueryString []struct{}  `json:"queryString"`
+	PostData    harPostData `json:"postData"`
+	HeadersSize int         `json:"headersSize"`
+	BodySize    int         `json:"bodySize"`
+}
+
+type harHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Encoding string `json:"_encoding,omitempty"`
+}
+
+type harResponse struct {
+	Status      int         `json:"status"`
+	StatusText  string      `json:"statusText"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []struct{}  `json:"cookies"`
+	Headers     []harHeader `json:"headers"`
+	Content     struct {
+		Size     int    `json:"size"`
+		MimeType string `json:"mimeType"`
+	} `json:"content"`
+	RedirectURL string `json:"redirectURL"`
+	HeadersSize int    `json:"headersSize"`
+	BodySize    int    `json:"bodySize"`
+}
+
+const amqpVersion = "AMQP/0-9-1"
+
+type har struct {
+	w       *bufio.Writer
+	version string
+	entries int
+}
+
+// HAR writes an HTTP Archive style document, whose entries are written as events arrive.
+func HAR(w io.Writer, version string) Writer {
+	return &har{w: bufio.NewWriter(w), version: version}
+}
+
+// begin writes the document up to its entries.
+func (h *har) begin() error {
+	creator, err := json.Marshal(map[string]string{"name": "coyote", "version": h.version})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(h.w, `{"log":{"version":"1.2","creator":%s,"entries":[`, creator)
+	return err
+}
+
+func (h *har) Write(e store.Event) error {
+	if h.entries == 0 {
+		if err := h.begin(); err != nil {
+			return err
+		}
+	} else if err := h.w.WriteByte(','); err != nil {
+		return err
+	}
+	h.entries++
+
+	entry := harEntry{
+		StartedDateTime: e.Timestamp,
+		Timings:         map[string]int{"send": 0, "wait": 0, "receive": 0},
+		ID:              e.ID,
+		SessionID:       e.SessionID,
+		Source:          e.Source,
+		Vhost:           e.Vhost,
+		Exchange:        e.Exchange,
+		RoutingKey:      e.RoutingKey,
+		MessageId:       e.MessageId,
+		CorrelationId:   e.CorrelationId,
+		ReplyTo:         e.ReplyTo,
+	}
+	entry.Request = harRequest{
+		Method: "PUBLISH",
+		URL: fmt.Sprintf("amqp://%s/%s/%s/%s", e.Source,
+			url.PathEscape(e.Vhost), url.PathEscape(e.Exchange), url.PathEscape(e.RoutingKey)),
+		HTTPVersion: amqpVersion,
+		Cookies:     []struct{}{},
+		Headers:     []harHeader{},
+		QueryString: []struct{}{},
+		HeadersSize: -1,
+		BodySize:    len(e.Body),
+	}
+	if headers, ok := e.HeaderTable(); ok {
+		for name, value := range headers {
+			entry.Request.Headers = append(entry.Request.Headers, harHeader{Name: name, Value: jsonpath.String(value)})
+		}
+		slices.SortFunc(entry.Request.Headers, func(a, b harHeader) int { return strings.Compare(a.Name, b.Name) })
+	}
+	entry.Request.PostData.Text, entry.Request.PostData.Encoding = body(e)
+	entry.Request.PostData.MimeType = "text/plain"
+	if entry.Request.PostData.Encoding != "" {
+		entry.Request.PostData.MimeType = "application/octet-stream"
+	} else if json.Valid(e.Body) {
+		entry.Request.PostData.MimeType = "application/json"
+	}
+	entry.Response = harResponse{HTTPVersion: amqpVersion, Cookies: []struct{}{}, Headers: []harHeader{}, HeadersSize: -1, BodySize: -1}
+	encoded, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = h.w.Write(encoded)
+	return err
+}
+
+func (h *har) Close() error {
+	if h.entries == 0 {
+		if err := h.begin(); err != nil {
+			return err
+		}
+	}
+	if _, err := h.w.WriteString("]}}\n"); err != nil {
+		return err
+	}
+	return h.w.Flush()
+}
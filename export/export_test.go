@@ -0,0 +1,144 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ghokun/coyote/store"
+	"github.com/parquet-go/parquet-go"
+)
+
+var events = []store.Event{
+	{ID: 1, SessionID: 1, Timestamp: time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC), Source: "prod", Vhost: "/", Exchange: "orders",
+		RoutingKey: "order.created", MessageId: "m1", CorrelationId: "42", ContentType: "application/json", AppId: "shop", UserId: "guest",
+		Headers: `{"tenant":"acme"}`, Body: []byte(`{"id":42}`), Redactions: []string{"card"},
+		Validation: &store.Validation{Contract: "order.json", Valid: false, Errors: []string{"missing sku"}}},
+	{ID: 2, Timestamp: time.Date(2026, 1, 2, 12, 0, 1, 0, time.UTC), Exchange: "raw", RoutingKey: "bytes",
+		Headers: "map[tenant:acme]", Body: []byte{0xff, 0x00}},
+}
+
+func write(t *testing.T, w Writer) {
+	t.Helper()
+	for _, e := range events {
+		if err := w.Write(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJSONL(t *testing.T) {
+	var out bytes.Buffer
+	write(t, JSONL(&out))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %q", out.String())
+	}
+	var first, second map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &second); err != nil {
+		t.Fatal(err)
+	}
+	if headers, ok := first["headers"].(map[string]any); !ok || headers["tenant"] != "acme" || first["body"] != `{"id":42}` ||
+		first["content_type"] != "application/json" || first["app_id"] != "shop" || first["user_id"] != "guest" ||
+		!strings.Contains(lines[0], `"redactions":["card"]`) || !strings.Contains(lines[0], `"validation":{"contract":"order.json","valid":false,"errors":["missing sku"]}`) {
+		t.Errorf("unexpected first line %s", lines[0])
+	}
+	if second["headers"] != "map[tenant:acme]" || second["body"] != "/wA=" || second["body_encoding"] != "base64" ||
+		second["redactions"] != nil || second["validation"] != nil {
+		t.Errorf("unexpected second line %s", lines[1])
+	}
+}
+
+func TestCSV(t *testing.T) {
+	tests := []struct {
+		header   bool
+		body     string
+		expected string
+	}{
+		{true, BodyNone, "id,session_id,timestamp,source,vhost,exchange,routing_key,message_id,correlation_id,reply_to," +
+			"content_type,app_id,user_id,headers,redactions,validation\n" +
+			`1,1,2026-01-02T12:00:00Z,prod,/,orders,order.created,m1,42,,application/json,shop,guest,"{""tenant"":""acme""}",` +
+			`"[""card""]","{""contract"":""order.json"",""valid"":false,""errors"":[""missing sku""]}"` + "\n" +
+			"2,0,2026-01-02T12:00:01Z,,,raw,bytes,,,,,,,map[tenant:acme],,\n"},
+		{false, BodyBase64, `1,1,2026-01-02T12:00:00Z,prod,/,orders,order.created,m1,42,,application/json,shop,guest,"{""tenant"":""acme""}",` +
+			`"[""card""]","{""contract"":""order.json"",""valid"":false,""errors"":[""missing sku""]}",eyJpZCI6NDJ9` + "\n" +
+			"2,0,2026-01-02T12:00:01Z,,,raw,bytes,,,,,,,map[tenant:acme],,,/wA=\n"},
+	}
+	for _, test := range tests {
+		var out bytes.Buffer
+		w, err := CSV(&out, test.header, test.body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		write(t, w)
+		if out.String() != test.expected {
+			t.Errorf("header %t, body %s: expected\n%s\ngot\n%s", test.header, test.body, test.expected, out.String())
+		}
+	}
+	if _, err := CSV(&bytes.Buffer{}, true, "hex"); err == nil {
+		t.Error("expected an error for an unknown body column")
+	}
+}
+
+func TestParquet(t *testing.T) {
+	var out bytes.Buffer
+	write(t, Parquet(&out))
+	rows, err := parquet.Read[parquetRow](bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(rows))
+	}
+	if *rows[0].SessionID != 1 || rows[0].Headers["tenant"] != "acme" || !rows[0].Timestamp.Equal(events[0].Timestamp) ||
+		rows[0].ContentType != "application/json" || rows[0].AppId != "shop" || rows[0].UserId != "guest" ||
+		len(rows[0].Redactions) != 1 || rows[0].Redactions[0] != "card" ||
+		rows[0].Validation == nil || rows[0].Validation.Contract != "order.json" || rows[0].Validation.Valid || len(rows[0].Validation.Errors) != 1 {
+		t.Errorf("unexpected first row %+v", rows[0])
+	}
+	if rows[1].SessionID != nil || len(rows[1].Headers) != 0 || !bytes.Equal(rows[1].Body, events[1].Body) ||
+		len(rows[1].Redactions) != 0 || rows[1].Validation != nil {
+		t.Errorf("unexpected second row %+v", rows[1])
+	}
+}
+
+func TestHAR(t *testing.T) {
+	var out bytes.Buffer
+	write(t, HAR(&out, "test"))
+	var document struct {
+		Log struct {
+			Version string     `json:"version"`
+			Entries []harEntry `json:"entries"`
+		} `json:"log"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &document); err != nil {
+		t.Fatalf("invalid HAR %s: %v", out.String(), err)
+	}
+	entries := document.Log.Entries
+	if document.Log.Version != "1.2" || len(entries) != 2 {
+		t.Fatalf("unexpected HAR %s", out.String())
+	}
+	if entries[0].Request.URL != "amqp://prod/%2F/orders/order.created" || entries[0].Request.PostData.MimeType != "application/json" ||
+		len(entries[0].Request.Headers) != 1 || entries[0].CorrelationId != "42" {
+		t.Errorf("unexpected first entry %+v", entries[0])
+	}
+	if entries[1].Request.PostData.Encoding != "base64" || entries[1].Request.PostData.MimeType != "application/octet-stream" {
+		t.Errorf("unexpected second entry %+v", entries[1])
+	}
+
+	out.Reset()
+	if err := HAR(&out, "test").Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !json.Valid(out.Bytes()) || !strings.Contains(out.String(), `"entries":[]`) {
+		t.Errorf("unexpected empty HAR %s", out.String())
+	}
+}
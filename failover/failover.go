@@ -0,0 +1,116 @@
+package failover
+
+import (
+	"crypto/tls"
+	"errors"
+	"log"
+	"math/rand/v2"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	failed "github.com/ghokun/coyote/error"
+)
+
+// dialTimeout bounds connecting to a single node, including the TLS and AMQP handshakes that follow.
+const dialTimeout = 30 * time.Second
+
+// Dialer connects to the first reachable node of a cluster. It replaces the address of the url being dialed,
+// so that the same credentials and vhost are used on every node.
+type Dialer struct {
+	nodes  []string
+	random bool
+	tls    *tls.Config
+}
+
+// New creates a dialer for the given node addresses, tried in order or in a random order on every dial.
+// Nodes are host:port addresses, or urls whose host and port are used. Given a TLS configuration, connections
+// are handshaken with it before they are returned, verifying each node by its own host name.
+func New(nodes []string, random bool, config *tls.Config) (*Dialer, error) {
+	d := &Dialer{random: random, tls: config}
+	for _, node := range nodes {
+		if strings.Contains(node, "://") {
+			u, err := url.Parse(node)
+			if err != nil {
+				return nil, failed.Because("failed to parse node "+node, err)
+			}
+			node = u.Host
+		}
+		if _, _, err := net.SplitHostPort(node); err != nil {
+			return nil, failed.Because("node "+node+" must be given as host:port", err)
+		}
+		d.nodes = append(d.nodes, node)
+	}
+	if len(d.nodes) == 0 {
+		return nil, failed.Because("no nodes to connect to", nil)
+	}
+	return d, nil
+}
+
+// Lookup returns the nodes of a DNS SRV record, e.g. _amqp._tcp.rabbitmq.example.com, ordered by priority and weight.
+func Lookup(service string) (nodes []string, err error) {
+	_, records, err := net.LookupSRV("", "", service)
+	if err != nil {
+		return nil, failed.Because("failed to look up nodes of "+service, err)
+	}
+	for _, record := range records {
+		nodes = append(nodes, net.JoinHostPort(strings.TrimSuffix(record.Target, "."), strconv.Itoa(int(record.Port))))
+	}
+	return nodes, nil
+}
+
+// Dial connects to the first node that accepts a connection, ignoring the given address.
+// It matches amqp091.Config.Dial, which is used both for the initial connection and for reconnecting.
+// The AMQP client verifies TLS connections against the host of the url rather than the node dialed, so the
+// handshake happens here, and urls dialed with a TLS configuration must not ask the client for another one.
+func (d *Dialer) Dial(network string, _ string) (net.Conn, error) {
+	nodes := d.nodes
+	if d.random {
+		nodes = slices.Clone(nodes)
+		rand.Shuffle(len(nodes), func(i, j int) { nodes[i], nodes[j] = nodes[j], nodes[i] })
+	}
+	var errs []error
+	for _, node := range nodes {
+		conn, err := net.DialTimeout(network, node, dialTimeout)
+		if err != nil {
+			log.Printf("⚠️ Node %s is not reachable: %v", color.YellowString(node), err)
+			errs = append(errs, err)
+			continue
+		}
+		// The deadline covers the handshakes, the AMQP client clears it once the connection is open.
+		if err := conn.SetDeadline(time.Now().Add(dialTimeout)); err != nil {
+			_ = conn.Close()
+			errs = append(errs, err)
+			continue
+		}
+		if d.tls != nil {
+			if conn, err = d.handshake(conn, node); err != nil {
+				log.Printf("⚠️ Node %s failed the TLS handshake: %v", color.YellowString(node), err)
+				errs = append(errs, err)
+				continue
+			}
+		}
+		log.Printf("🖧 Connected to node %s", color.YellowString(node))
+		return conn, nil
+	}
+	return nil, failed.Because("no node is reachable", errors.Join(errs...))
+}
+
+// handshake secures a connection to a node, whose host name is what its certificate is verified against unless
+// the configuration names the server.
+func (d *Dialer) handshake(conn net.Conn, node string) (net.Conn, error) {
+	config := d.tls.Clone()
+	if config.ServerName == "" {
+		config.ServerName, _, _ = net.SplitHostPort(node)
+	}
+	client := tls.Client(conn, config)
+	if err := client.Handshake(); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return client, nil
+}
@@ -0,0 +1,92 @@
+package failover
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	d, err := New([]string{"node1:5672", "amqps://user@node2:5671/vhost"}, false, nil)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(d.nodes) != 2 || d.nodes[0] != "node1:5672" || d.nodes[1] != "node2:5671" {
+		t.Errorf("unexpected nodes %v", d.nodes)
+	}
+	for _, nodes := range [][]string{nil, {"node1"}} {
+		if _, err := New(nodes, false, nil); err == nil {
+			t.Errorf("%v: expected an error", nodes)
+		}
+	}
+}
+
+func TestDial(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	// A closed listener leaves behind an address nothing listens on.
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unreachable := closed.Addr().String()
+	closed.Close()
+
+	for _, random := range []bool{false, true} {
+		d, err := New([]string{unreachable, listener.Addr().String()}, random, nil)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		conn, err := d.Dial("tcp", "ignored:5672")
+		if err != nil {
+			t.Fatalf("random %v: expected to fail over to %s, got %v", random, listener.Addr(), err)
+		}
+		if conn.RemoteAddr().String() != listener.Addr().String() {
+			t.Errorf("random %v: connected to %s", random, conn.RemoteAddr())
+		}
+		conn.Close()
+	}
+
+	d, _ := New([]string{unreachable}, false, nil)
+	if _, err := d.Dial("tcp", "ignored:5672"); err == nil {
+		t.Error("expected an error when no node is reachable")
+	}
+}
+
+func TestDialTLS(t *testing.T) {
+	// The certificate of the test server is valid for 127.0.0.1, but not for localhost.
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := server.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+
+	d, err := New([]string{net.JoinHostPort("localhost", port), net.JoinHostPort("127.0.0.1", port)}, false, &tls.Config{RootCAs: roots})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	conn, err := d.Dial("tcp", "ignored:5671")
+	if err != nil {
+		t.Fatalf("expected to fail over to the node the certificate is valid for, got %v", err)
+	}
+	defer conn.Close()
+	client, ok := conn.(*tls.Conn)
+	if !ok {
+		t.Fatalf("expected a TLS connection, got %T", conn)
+	}
+	if len(client.ConnectionState().VerifiedChains) == 0 {
+		t.Error("expected the certificate of the node to be verified")
+	}
+
+	d, _ = New([]string{net.JoinHostPort("localhost", port)}, false, &tls.Config{RootCAs: roots})
+	if _, err := d.Dial("tcp", "ignored:5671"); err == nil {
+		t.Error("expected a node the certificate is not valid for to fail")
+	}
+}
@@ -0,0 +1,272 @@
+package hook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unicode"
+
+	failed "github.com/ghokun/coyote/error"
+	"github.com/ghokun/coyote/export"
+	"github.com/ghokun/coyote/jsonpath"
+	"github.com/ghokun/coyote/store"
+)
+
+// Options tell how the command is run.
+type Options struct {
+	// Command is run by sh -c.
+	Command string
+	// Batch is the number of messages the command is run with, 1 runs it per message.
+	Batch int
+	// BatchTimeout is how long the first message of an incomplete batch waits before Expire runs it, 0 waits until
+	// the batch is complete.
+	BatchTimeout time.Duration
+	// Concurrency is the number of commands run at once.
+	Concurrency int
+	// Timeout kills commands running longer, 0 lets them run.
+	Timeout time.Duration
+	// Retries is the number of times a failed command is run again, Backoff the delay before the first retry,
+	// doubling with every retry.
+	Retries int
+	Backoff time.Duration
+}
+
+// Runner runs a command with captured messages written to its stdin as JSON lines, in the format of coyote export.
+// Commands run with a single message get its properties as environment variables too:
+//
+//	COYOTE_SOURCE, COYOTE_VHOST, COYOTE_EXCHANGE, COYOTE_ROUTING_KEY, COYOTE_MESSAGE_ID, COYOTE_CORRELATION_ID,
+//	COYOTE_REPLY_TO, COYOTE_CONTENT_TYPE, COYOTE_APP_ID, COYOTE_USER_ID, COYOTE_TIMESTAMP and COYOTE_HEADER_<NAME>
+//
+// while every command gets the number of messages as COYOTE_BATCH_SIZE.
+type Runner struct {
+	options   Options
+	onFailure func(events []store.Event, err error)
+	pending   []pending
+	since     time.Time
+	slots     chan struct{}
+	running   sync.WaitGroup
+	halted    atomic.Bool
+	// stopping is cancelled by Halt and Close, interrupting commands waiting to be retried.
+	stopping context.Context
+	stop     context.CancelFunc
+}
+
+// errInterrupted is what commands waiting to be retried fail with when the runner is halted or closed.
+var errInterrupted = errors.New("retries interrupted")
+
+// pending is a message waiting for its batch to be complete, with how to settle it once its command ran.
+type pending struct {
+	event  store.Event
+	settle func(err error)
+}
+
+// New validates the options. Commands that still fail after their retries are reported to onFailure, which may be
+// called concurrently.
+func New(options Options, onFailure func(events []store.Event, err error)) (*Runner, error) {
+	switch {
+	case strings.TrimSpace(options.Command) == "":
+		return nil, failed.Because("exec command must be set", nil)
+	case options.Batch < 1:
+		return nil, failed.Because("exec batch must be at least 1", nil)
+	case options.Concurrency < 1:
+		return nil, failed.Because("exec concurrency must be at least 1", nil)
+	case options.Timeout < 0 || options.BatchTimeout < 0 || options.Retries < 0 || options.Backoff < 0:
+		return nil, failed.Because("exec timeouts, retries and backoff must not be negative", nil)
+	}
+	stopping, stop := context.WithCancel(context.Background())
+	return &Runner{options: options, onFailure: onFailure, slots: make(chan struct{}, options.Concurrency), stopping: stopping, stop: stop}, nil
+}
+
+// Send queues a message, running the command once a batch is complete. It blocks while as many commands as allowed
+// are running, so that messages are not buffered without bound. Once the command ran, settle is called with nil, or
+// with the error it failed with after its retries. A nil runner runs nothing.
+func (r *Runner) Send(e store.Event, settle func(err error)) {
+	if r == nil || r.halted.Load() {
+		return
+	}
+	if len(r.pending) == 0 {
+		r.since = time.Now()
+	}
+	r.pending = append(r.pending, pending{event: e, settle: settle})
+	if len(r.pending) >= r.options.Batch {
+		r.Flush()
+	}
+}
+
+// Expire runs the command with an incomplete batch whose first message waited for the batch timeout by now, so that
+// messages are not held back while few arrive. It is called from the goroutine sending messages.
+func (r *Runner) Expire(now time.Time) {
+	if r == nil || r.halted.Load() || r.options.BatchTimeout == 0 {
+		return
+	}
+	if len(r.pending) > 0 && now.Sub(r.since) >= r.options.BatchTimeout {
+		r.Flush()
+	}
+}
+
+// Flush runs the command with the messages of an incomplete batch.
+func (r *Runner) Flush() {
+	if len(r.pending) == 0 {
+		return
+	}
+	batch := r.pending
+	r.pending = nil
+	r.slots <- struct{}{}
+	r.running.Add(1)
+	go func() {
+		defer func() {
+			<-r.slots
+			r.running.Done()
+		}()
+		// Batches waiting for a slot when the runner was halted are left unsettled.
+		if r.halted.Load() {
+			return
+		}
+		events := make([]store.Event, len(batch))
+		for i, p := range batch {
+			events[i] = p.event
+		}
+		err := r.run(events)
+		// So are batches whose retries were interrupted.
+		if errors.Is(err, errInterrupted) {
+			return
+		}
+		if err != nil {
+			r.onFailure(events, err)
+		}
+		for _, p := range batch {
+			p.settle(err)
+		}
+	}()
+}
+
+// Halt stops running the command, messages sent afterwards, batches not started yet and batches waiting to be retried
+// are left unsettled. Commands already running complete.
+func (r *Runner) Halt() {
+	if r != nil {
+		r.halted.Store(true)
+		r.stop()
+	}
+}
+
+// Halted reports whether the runner was halted, a nil runner never is.
+func (r *Runner) Halted() bool {
+	return r != nil && r.halted.Load()
+}
+
+// Close runs the command with the last incomplete batch, unless halted, and waits for every command to complete.
+// Commands are not retried anymore, so that closing is not delayed by their backoff, and their batches are left
+// unsettled.
+func (r *Runner) Close() {
+	if r == nil {
+		return
+	}
+	if !r.halted.Load() {
+		r.Flush()
+	}
+	r.stop()
+	r.running.Wait()
+}
+
+// run runs the command until it succeeds or runs out of retries.
+func (r *Runner) run(events []store.Event) error {
+	var stdin bytes.Buffer
+	w := export.JSONL(&stdin)
+	for _, e := range events {
+		if err := w.Write(e); err != nil {
+			return failed.Because("failed to encode message", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		return failed.Because("failed to encode message", err)
+	}
+	env := append(os.Environ(), "COYOTE_BATCH_SIZE="+strconv.Itoa(len(events)))
+	if len(events) == 1 {
+		env = append(env, environment(events[0])...)
+	}
+	backoff := r.options.Backoff
+	var err error
+	for attempt := 0; attempt <= r.options.Retries; attempt++ {
+		if attempt > 0 {
+			timer := time.NewTimer(backoff)
+			select {
+			case <-r.stopping.Done():
+				timer.Stop()
+				return errInterrupted
+			case <-timer.C:
+			}
+			backoff *= 2
+		}
+		if err = r.attempt(stdin.Bytes(), env); err == nil {
+			return nil
+		}
+	}
+	if r.options.Retries > 0 {
+		return failed.Because("command failed "+strconv.Itoa(r.options.Retries+1)+" times", err)
+	}
+	return err
+}
+
+func (r *Runner) attempt(stdin []byte, env []string) error {
+	ctx := context.Background()
+	if r.options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.options.Timeout)
+		defer cancel()
+	}
+	cmd := exec.CommandContext(ctx, "sh", "-c", r.options.Command)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr, cmd.Env = bytes.NewReader(stdin), os.Stdout, os.Stderr, env
+	// Commands run in a process group of their own, so that a timeout kills the children of the shell too.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return failed.Because("command timed out after "+r.options.Timeout.String(), nil)
+	}
+	if err != nil {
+		return failed.Because("command failed", err)
+	}
+	return nil
+}
+
+// environment returns the properties of a message as environment variables.
+func environment(e store.Event) []string {
+	env := []string{
+		"COYOTE_SOURCE=" + e.Source,
+		"COYOTE_VHOST=" + e.Vhost,
+		"COYOTE_EXCHANGE=" + e.Exchange,
+		"COYOTE_ROUTING_KEY=" + e.RoutingKey,
+		"COYOTE_MESSAGE_ID=" + e.MessageId,
+		"COYOTE_CORRELATION_ID=" + e.CorrelationId,
+		"COYOTE_REPLY_TO=" + e.ReplyTo,
+		"COYOTE_CONTENT_TYPE=" + e.ContentType,
+		"COYOTE_APP_ID=" + e.AppId,
+		"COYOTE_USER_ID=" + e.UserId,
+		"COYOTE_TIMESTAMP=" + e.Timestamp.Format(time.RFC3339Nano),
+	}
+	headers, _ := e.HeaderTable()
+	for name, value := range headers {
+		env = append(env, "COYOTE_HEADER_"+variable(name)+"="+jsonpath.String(value))
+	}
+	return env
+}
+
+// variable turns a header name into an environment variable name, e.g. x-tenant-id into X_TENANT_ID.
+func variable(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return unicode.ToUpper(r)
+		}
+		return '_'
+	}, name)
+}
@@ -0,0 +1,205 @@
+package hook
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ghokun/coyote/store"
+)
+
+func ignore(error) {}
+
+func event(id int64, routingKey string) store.Event {
+	return store.Event{ID: id, Exchange: "orders", RoutingKey: routingKey, Headers: `{"x-tenant-id":"acme","retries":2}`, Body: []byte(`{"id":1}`)}
+}
+
+func TestRunner(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	r, err := New(Options{
+		Command:     `echo "$COYOTE_BATCH_SIZE $COYOTE_ROUTING_KEY $COYOTE_HEADER_X_TENANT_ID $COYOTE_HEADER_RETRIES $(cat)" >> ` + out,
+		Batch:       1,
+		Concurrency: 1,
+	}, func(events []store.Event, err error) {
+		t.Errorf("expected the command to succeed, got %v", err)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Send(event(1, "order.created"), ignore)
+	r.Send(event(2, "order.paid"), ignore)
+	r.Close()
+	content, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `1 order.created acme 2 {"id":1,"timestamp":"0001-01-01T00:00:00Z","exchange":"orders","routing_key":"order.created","headers":{"retries":2,"x-tenant-id":"acme"},"body":"{\"id\":1}"}` + "\n" +
+		`1 order.paid acme 2 {"id":2,"timestamp":"0001-01-01T00:00:00Z","exchange":"orders","routing_key":"order.paid","headers":{"retries":2,"x-tenant-id":"acme"},"body":"{\"id\":1}"}` + "\n"
+	if string(content) != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, content)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	r, err := New(Options{Command: `echo "$COYOTE_BATCH_SIZE $(wc -l) [$COYOTE_ROUTING_KEY]" >> ` + out, Batch: 2, Concurrency: 1}, func(events []store.Event, err error) {
+		t.Errorf("expected the command to succeed, got %v", err)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 3 {
+		r.Send(event(int64(i), "order.created"), ignore)
+	}
+	r.Close()
+	content, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Fields(strings.ReplaceAll(string(content), "\n", " | ")); strings.Join(lines, " ") != "2 2 [] | 1 1 [order.created] |" {
+		t.Errorf("expected a batch of 2 then the last message, got %q", content)
+	}
+}
+
+func TestBatchTimeout(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	r, err := New(Options{Command: `wc -l >> ` + out, Batch: 3, BatchTimeout: time.Minute, Concurrency: 1}, func(events []store.Event, err error) {
+		t.Errorf("expected the command to succeed, got %v", err)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Send(event(1, "order.created"), ignore)
+	r.Expire(time.Now())
+	r.Send(event(2, "order.created"), ignore)
+	r.Expire(time.Now().Add(time.Minute))
+	r.Send(event(3, "order.created"), ignore)
+	r.Close()
+	content, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Fields(string(content)); strings.Join(lines, " ") != "2 1" {
+		t.Errorf("expected the incomplete batch to run once it expired, got %q", content)
+	}
+}
+
+func TestFailures(t *testing.T) {
+	dir := t.TempDir()
+	attempts := filepath.Join(dir, "attempts")
+	var mu sync.Mutex
+	var failures []string
+	onFailure := func(events []store.Event, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		failures = append(failures, events[0].RoutingKey+": "+err.Error())
+	}
+
+	r, err := New(Options{Command: `echo x >> ` + attempts + `; test "$COYOTE_ROUTING_KEY" = order.created`, Batch: 1, Concurrency: 2, Retries: 2, Backoff: time.Millisecond}, onFailure)
+	if err != nil {
+		t.Fatal(err)
+	}
+	settled := map[string]error{}
+	settle := func(routingKey string) func(err error) {
+		return func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			settled[routingKey] = err
+		}
+	}
+	r.Send(event(1, "order.created"), settle("order.created"))
+	r.Send(event(2, "order.failed"), settle("order.failed"))
+	// Closing interrupts retries, so the test waits for both messages to be settled first.
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		mu.Lock()
+		done := len(settled) == 2
+		mu.Unlock()
+		if done {
+			break
+		}
+	}
+	r.Close()
+	if err, found := settled["order.created"]; !found || err != nil {
+		t.Errorf("expected order.created to be settled as succeeded, got %v", err)
+	}
+	if settled["order.failed"] == nil {
+		t.Error("expected order.failed to be settled as failed")
+	}
+	if len(failures) != 1 || !strings.HasPrefix(failures[0], "order.failed: ") || !strings.Contains(failures[0], "command failed 3 times") {
+		t.Errorf("expected order.failed to fail after 3 attempts, got %q", failures)
+	}
+	if content, _ := os.ReadFile(attempts); strings.Count(string(content), "x") != 4 {
+		t.Errorf("expected 1 attempt and 3 attempts, got %d", strings.Count(string(content), "x"))
+	}
+
+	failures = nil
+	r, err = New(Options{Command: "sleep 5", Batch: 1, Concurrency: 1, Timeout: 50 * time.Millisecond}, onFailure)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	r.Send(event(1, "order.created"), ignore)
+	r.Close()
+	if len(failures) != 1 || !strings.Contains(failures[0], "timed out") || time.Since(start) > 3*time.Second {
+		t.Errorf("expected the command to time out, got %q after %s", failures, time.Since(start))
+	}
+
+	for _, options := range []Options{{Batch: 1, Concurrency: 1}, {Command: "true", Concurrency: 1}, {Command: "true", Batch: 1}, {Command: "true", Batch: 1, Concurrency: 1, Retries: -1},
+		{Command: "true", Batch: 1, Concurrency: 1, BatchTimeout: -1}} {
+		if _, err := New(options, onFailure); err == nil {
+			t.Errorf("expected %+v to fail", options)
+		}
+	}
+}
+
+func TestHalt(t *testing.T) {
+	attempts := filepath.Join(t.TempDir(), "attempts")
+	var r *Runner
+	r, err := New(Options{Command: `echo x >> ` + attempts + `; false`, Batch: 1, Concurrency: 1}, func(events []store.Event, err error) {
+		r.Halt()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var settled []string
+	for _, routingKey := range []string{"order.created", "order.paid", "order.shipped"} {
+		r.Send(event(1, routingKey), func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			settled = append(settled, routingKey)
+		})
+	}
+	r.Close()
+	if !r.Halted() || len(settled) != 1 || settled[0] != "order.created" {
+		t.Errorf("expected only order.created to run and be settled once halted, got %q", settled)
+	}
+	if content, _ := os.ReadFile(attempts); strings.Count(string(content), "x") != 1 {
+		t.Errorf("expected the command to run once, got %d", strings.Count(string(content), "x"))
+	}
+	if (*Runner)(nil).Halted() {
+		t.Error("expected a nil runner not to be halted")
+	}
+}
+
+func TestCloseInterruptsRetries(t *testing.T) {
+	r, err := New(Options{Command: "false", Batch: 1, Concurrency: 1, Retries: 3, Backoff: time.Hour}, func(events []store.Event, err error) {
+		t.Errorf("expected no failure to be reported once closed, got %v", err)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	settled := false
+	r.Send(event(1, "order.created"), func(err error) { settled = true })
+	start := time.Now()
+	r.Close()
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("expected closing not to wait for the backoff, took %s", elapsed)
+	}
+	if settled {
+		t.Error("expected the message whose retries were interrupted to be left unsettled")
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/ghokun/coyote/encryption"
+	failed "github.com/ghokun/coyote/error"
+	"github.com/ghokun/coyote/importer"
+	"github.com/ghokun/coyote/store"
+	"github.com/urfave/cli/v3"
+)
+
+const importUsage = `coyote import [options] PATH [PATH...]
+
+Imports message dumps of other tools into a store, so that they can be read like captured events.
+Unless --format is given, directories are read as raw bodies, .json files as management API messages
+and other files as JSON lines. Files encrypted by coyote export are decrypted with the given key.
+
+Formats:
+ management # JSON array returned by "get messages" of the management API
+ jsonl      # A message per line, as written by coyote export or returned by the management API
+ raw        # Directory holding a message body per file
+
+Examples:
+# Import messages fetched through the management API of production
+coyote import --store events.sqlite --source prod --vhost / messages.json
+
+# Import an export of another store
+coyote import --store events.sqlite export.jsonl
+
+# Import a directory of bodies published to 'orders' with the file names as routing keys
+coyote import --store events.sqlite --format raw --exchange orders bodies/`
+
+func importCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "import",
+		Usage:     "Imports management API messages, JSON lines or raw bodies into a store.",
+		UsageText: importUsage,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "store",
+				Usage: "SQLite filename to import events into.",
+			},
+			&cli.StringFlag{
+				Name:  "format",
+				Usage: "Format of the imported paths, one of management, jsonl or raw.",
+			},
+			&cli.StringFlag{
+				Name:  "source",
+				Usage: "Source to tag imported events with, overriding the source of exported events.",
+			},
+			&cli.StringFlag{
+				Name:  "vhost",
+				Usage: "Vhost to tag imported events with, overriding the vhost of exported events.",
+			},
+			&cli.StringFlag{
+				Name:  "exchange",
+				Usage: "Exchange of raw bodies.",
+			},
+			&cli.StringFlag{
+				Name:  "routing-key",
+				Usage: "Routing key of raw bodies, their file names without extension if omitted.",
+			},
+		},
+		Action: importEvents,
+	}
+}
+
+func importEvents(_ context.Context, cli *cli.Command) error {
+	if !cli.IsSet("store") {
+		return failed.Because("store must be set", nil)
+	}
+	if cli.NArg() == 0 {
+		return failed.Because("at least one path is required", nil)
+	}
+	st, err := openStore(cli, cli.String("store"))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := st.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	for _, path := range cli.Args().Slice() {
+		imported := 0
+		err := st.InsertEvents(func(insert func(e store.Event) error) error {
+			return importPath(cli, path, func(e store.Event) error {
+				if cli.IsSet("source") {
+					e.Source = cli.String("source")
+				}
+				if cli.IsSet("vhost") {
+					e.Vhost = cli.String("vhost")
+				}
+				imported++
+				return insert(e)
+			})
+		})
+		if err != nil {
+			return failed.Because("failed to import "+path, err)
+		}
+		log.Printf("📥 Imported %s events from %s", color.YellowString("%d", imported), color.YellowString(path))
+	}
+	return nil
+}
+
+// importPath reads the path in the format given with --format, or the one its kind and extension suggest.
+func importPath(cli *cli.Command, path string, fn func(e store.Event) error) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	format := cli.String("format")
+	if format == "" {
+		switch {
+		case info.IsDir():
+			format = "raw"
+		case filepath.Ext(strings.TrimSuffix(path, encryptedExtension)) == ".json":
+			format = "management"
+		default:
+			format = "jsonl"
+		}
+	}
+	if format == "raw" {
+		if !info.IsDir() {
+			return failed.Because("raw bodies must be imported from a directory", nil)
+		}
+		return importer.Directory(path, cli.String("exchange"), cli.String("routing-key"), fn)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Fatal(failed.Because("failed to close "+path, err))
+		}
+	}()
+	buffered := bufio.NewReader(file)
+	var content io.Reader = buffered
+	if encryption.IsEncrypted(buffered) {
+		secret, err := encryptionKey(cli)
+		if err != nil {
+			return err
+		}
+		if secret == nil {
+			return failed.Because(path+" is encrypted, its key is required with --key-file, --key-env or --key-prompt", nil)
+		}
+		if content, err = encryption.NewReader(content, secret); err != nil {
+			return err
+		}
+	}
+	switch format {
+	case "management":
+		return importer.Management(content, fn)
+	case "jsonl":
+		return importer.JSONL(content, fn)
+	default:
+		return failed.Because("unknown import format "+format, nil)
+	}
+}
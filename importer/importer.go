@@ -0,0 +1,213 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	failed "github.com/ghokun/coyote/error"
+	"github.com/ghokun/coyote/store"
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// maxLine bounds the length of a JSON line, which holds a whole message body.
+const maxLine = 64 * 1024 * 1024
+
+// message is a message as returned by the "get messages" endpoint of the management API,
+// or as exported by coyote export. Lines of JSON Lines dumps may be either.
+type message struct {
+	// Fields of the management API
+	Payload         *string    `json:"payload"`
+	PayloadEncoding string     `json:"payload_encoding"`
+	Properties      properties `json:"properties"`
+
+	// Fields of coyote export
+	Timestamp     time.Time         `json:"timestamp"`
+	Source        string            `json:"source"`
+	Vhost         string            `json:"vhost"`
+	MessageId     string            `json:"message_id"`
+	CorrelationId string            `json:"correlation_id"`
+	ReplyTo       string            `json:"reply_to"`
+	ContentType   string            `json:"content_type"`
+	AppId         string            `json:"app_id"`
+	UserId        string            `json:"user_id"`
+	Headers       json.RawMessage   `json:"headers"`
+	Body          string            `json:"body"`
+	BodyEncoding  string            `json:"body_encoding"`
+	Redactions    []string          `json:"redactions"`
+	Validation    *store.Validation `json:"validation"`
+
+	// Fields of both
+	Exchange   string `json:"exchange"`
+	RoutingKey string `json:"routing_key"`
+}
+
+type properties struct {
+	MessageId     string        `json:"message_id"`
+	CorrelationId string        `json:"correlation_id"`
+	ReplyTo       string        `json:"reply_to"`
+	ContentType   string        `json:"content_type"`
+	AppId         string        `json:"app_id"`
+	UserId        string        `json:"user_id"`
+	Headers       amqp091.Table `json:"headers"`
+	Timestamp     int64         `json:"timestamp"`
+}
+
+// UnmarshalJSON reads the properties, which the management API returns as an empty array when a message has none.
+func (p *properties) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(bytes.TrimSpace(data), []byte("[]")) {
+		*p = properties{}
+		return nil
+	}
+	type plain properties
+	return json.Unmarshal(data, (*plain)(p))
+}
+
+// event maps the message to the event schema, capture times the management API does not know default to now.
+func (m message) event() (e store.Event, err error) {
+	e = store.Event{
+		Timestamp:  m.Timestamp,
+		Source:     m.Source,
+		Vhost:      m.Vhost,
+		Exchange:   m.Exchange,
+		RoutingKey: m.RoutingKey,
+	}
+	if m.Payload != nil {
+		e.MessageId, e.CorrelationId, e.ReplyTo = m.Properties.MessageId, m.Properties.CorrelationId, m.Properties.ReplyTo
+		e.ContentType, e.AppId, e.UserId = m.Properties.ContentType, m.Properties.AppId, m.Properties.UserId
+		e.Headers = store.EncodeHeaders(m.Properties.Headers)
+		e.Body, err = decode(*m.Payload, m.PayloadEncoding)
+		if m.Properties.Timestamp != 0 {
+			e.Timestamp = time.Unix(m.Properties.Timestamp, 0)
+		}
+	} else {
+		e.MessageId, e.CorrelationId, e.ReplyTo = m.MessageId, m.CorrelationId, m.ReplyTo
+		e.ContentType, e.AppId, e.UserId = m.ContentType, m.AppId, m.UserId
+		e.Redactions, e.Validation = m.Redactions, m.Validation
+		e.Headers = headers(m.Headers)
+		e.Body, err = decode(m.Body, m.BodyEncoding)
+	}
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now()
+	}
+	return e, err
+}
+
+// headers keeps exported headers as stored, JSON objects are stored as they are and text as it was read.
+func headers(raw json.RawMessage) string {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, raw); err != nil || compact.Len() == 0 || compact.String() == "null" {
+		return "{}"
+	}
+	return compact.String()
+}
+
+func decode(body string, encoding string) ([]byte, error) {
+	switch encoding {
+	case "", "string":
+		return []byte(body), nil
+	case "base64":
+		decoded, err := base64.StdEncoding.DecodeString(body)
+		if err != nil {
+			return nil, failed.Because("failed to decode base64 body", err)
+		}
+		return decoded, nil
+	default:
+		return nil, failed.Because("unknown body encoding "+encoding, nil)
+	}
+}
+
+// Management reads the JSON array returned by the "get messages" endpoint of the management API.
+// Messages are decoded one at a time, so that large dumps are streamed.
+func Management(r io.Reader, fn func(e store.Event) error) error {
+	decoder := json.NewDecoder(r)
+	if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+		return failed.Because("expected a JSON array of messages", err)
+	}
+	for i := 0; decoder.More(); i++ {
+		var m message
+		if err := decoder.Decode(&m); err != nil {
+			return failed.Because("failed to decode message "+strconv.Itoa(i), err)
+		}
+		if m.Payload == nil {
+			return failed.Because("message "+strconv.Itoa(i)+" has no payload", nil)
+		}
+		e, err := m.event()
+		if err != nil {
+			return failed.Because("failed to read message "+strconv.Itoa(i), err)
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	if _, err := decoder.Token(); err != nil {
+		return failed.Because("failed to decode messages", err)
+	}
+	return nil
+}
+
+// JSONL reads a message per line, either as written by coyote export or as returned by the management API.
+// Empty lines are skipped.
+func JSONL(r io.Reader, fn func(e store.Event) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxLine)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var m message
+		if err := json.Unmarshal(scanner.Bytes(), &m); err != nil {
+			return failed.Because("failed to decode line "+strconv.Itoa(line), err)
+		}
+		e, err := m.event()
+		if err != nil {
+			return failed.Because("failed to read line "+strconv.Itoa(line), err)
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return failed.Because("failed to read lines", err)
+	}
+	return nil
+}
+
+// Directory reads every file under dir as the body of a message, in the order of their paths. Messages are published
+// to the exchange with the routing key, or with the file name without its extension when routingKey is empty,
+// and were captured when the file was last modified.
+func Directory(dir string, exchange string, routingKey string, fn func(e store.Event) error) error {
+	return filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return failed.Because("failed to read "+path, err)
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return failed.Because("failed to read "+path, err)
+		}
+		body, err := os.ReadFile(path)
+		if err != nil {
+			return failed.Because("failed to read "+path, err)
+		}
+		e := store.Event{Timestamp: info.ModTime(), Exchange: exchange, RoutingKey: routingKey, Headers: "{}", Body: body}
+		if e.RoutingKey == "" {
+			e.RoutingKey = strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		}
+		return fn(e)
+	})
+}
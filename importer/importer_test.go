@@ -0,0 +1,101 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ghokun/coyote/store"
+)
+
+func collect(t *testing.T, read func(fn func(e store.Event) error) error) []store.Event {
+	t.Helper()
+	var events []store.Event
+	if err := read(func(e store.Event) error {
+		events = append(events, e)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+func TestManagement(t *testing.T) {
+	dump := `[
+	  {"exchange":"orders","routing_key":"order.paid","payload":"{\"id\":9}","payload_encoding":"string",
+	   "properties":{"message_id":"m9","correlation_id":"9","reply_to":"replies","content_type":"application/json","app_id":"shop","user_id":"guest","timestamp":1767355200,"headers":{"tenant":"acme"}}},
+	  {"exchange":"","routing_key":"raw","payload":"/wA=","payload_encoding":"base64","properties":[]}
+	]`
+	events := collect(t, func(fn func(e store.Event) error) error { return Management(strings.NewReader(dump), fn) })
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+	first := events[0]
+	if first.Exchange != "orders" || first.RoutingKey != "order.paid" || first.MessageId != "m9" || first.CorrelationId != "9" || first.ReplyTo != "replies" ||
+		first.ContentType != "application/json" || first.AppId != "shop" || first.UserId != "guest" || first.Headers != `{"tenant":"acme"}` || string(first.Body) != `{"id":9}` || !first.Timestamp.Equal(time.Unix(1767355200, 0)) {
+		t.Errorf("unexpected first event %+v", first)
+	}
+	if second := events[1]; second.Headers != "{}" || string(second.Body) != "\xff\x00" || second.Timestamp.IsZero() {
+		t.Errorf("unexpected second event %+v", second)
+	}
+
+	if err := Management(strings.NewReader(`{"payload":""}`), func(store.Event) error { return nil }); err == nil {
+		t.Error("expected an error for a dump that is not an array")
+	}
+	if err := Management(strings.NewReader(`[{"payload":"x","payload_encoding":"hex"}]`), func(store.Event) error { return nil }); err == nil {
+		t.Error("expected an error for an unknown payload encoding")
+	}
+}
+
+func TestJSONL(t *testing.T) {
+	dump := `{"id":1,"timestamp":"2026-01-02T12:00:00Z","source":"prod","vhost":"/","exchange":"orders","routing_key":"order.created","message_id":"m1","correlation_id":"1","content_type":"application/json","headers":{"tenant": "acme"},"body":"{}","redactions":["card"]}
+
+{"id":2,"timestamp":"2026-01-02T12:00:01Z","exchange":"orders","routing_key":"order.paid","headers":"map[tenant:acme]","body":"/w==","body_encoding":"base64"}
+{"exchange":"orders","routing_key":"order.shipped","payload":"shipped","payload_encoding":"string","properties":{}}
+`
+	events := collect(t, func(fn func(e store.Event) error) error { return JSONL(strings.NewReader(dump), fn) })
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(events))
+	}
+	if first := events[0]; first.Source != "prod" || first.MessageId != "m1" || first.ContentType != "application/json" || first.Vhost != "/" || first.Headers != `{"tenant":"acme"}` || string(first.Body) != "{}" ||
+		len(first.Redactions) != 1 || first.Redactions[0] != "card" || !first.Timestamp.Equal(time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected first event %+v", first)
+	}
+	if second := events[1]; second.Headers != "map[tenant:acme]" || string(second.Body) != "\xff" {
+		t.Errorf("unexpected second event %+v", second)
+	}
+	if third := events[2]; third.RoutingKey != "order.shipped" || string(third.Body) != "shipped" || third.Headers != "{}" {
+		t.Errorf("unexpected third event %+v", third)
+	}
+
+	if err := JSONL(strings.NewReader("{}\nnot json\n"), func(store.Event) error { return nil }); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected an error naming line 2, got %v", err)
+	}
+}
+
+func TestDirectory(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "nested"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, body := range map[string]string{"order.created.json": `{"id":1}`, "nested/order.paid.json": `{"id":2}`} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	events := collect(t, func(fn func(e store.Event) error) error { return Directory(dir, "orders", "", fn) })
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+	// Paths are walked in lexical order, so the nested directory comes first.
+	if events[0].RoutingKey != "order.paid" || events[1].RoutingKey != "order.created" || events[1].Exchange != "orders" || string(events[1].Body) != `{"id":1}` {
+		t.Errorf("unexpected events %+v", events)
+	}
+
+	events = collect(t, func(fn func(e store.Event) error) error { return Directory(dir, "orders", "order.any", fn) })
+	if events[0].RoutingKey != "order.any" || events[1].RoutingKey != "order.any" {
+		t.Errorf("expected the given routing key, got %+v", events)
+	}
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/cqroot/prompt"
+	"github.com/cqroot/prompt/input"
+	"github.com/fatih/color"
+	failed "github.com/ghokun/coyote/error"
+	"github.com/ghokun/coyote/management"
+	"github.com/ghokun/coyote/metrics"
+	"github.com/ghokun/coyote/store"
+	"github.com/google/uuid"
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/urfave/cli/v3"
+)
+
+// exchangeFlag is the exchange & routing key combinations to bind the interceptor queue to.
+// Capture only flags of the root command are local, so that subcommands can declare their own.
+func exchangeFlag(local bool) *cli.StringMapFlag {
+	return &cli.StringMapFlag{
+		Name:  "exchange",
+		Local: local,
+		Usage: "Exchange & routing key combinations to listen messages, exchanges may name their source and vhost as source:exchange@vhost.",
+	}
+}
+
+// vhostSeparator separates an exchange from the vhost it is in, as in orders@tenant-a.
+const vhostSeparator = "@"
+
+// sourceSeparator separates the source an exchange is bound in from the exchange, as in staging:orders.
+const sourceSeparator = ":"
+
+// interceptorBindings returns the exchange & routing key pairs of --exchange for a source grouped by vhost.
+// Exchanges that do not name their vhost are in the vhost of the source url, and exchanges that do not
+// name their source are bound in every source. When --exchange is omitted, the exchanges of the vhost
+// of the source url are listed through the management API to choose from.
+func interceptorBindings(cli *cli.Command, amqpUrl *url.URL, sourceName string) (bindings map[string]map[string]string, err error) {
+	vhost := management.DefaultVhost(amqpUrl)
+	if cli.IsSet("exchange") {
+		all, err := sources(cli)
+		if err != nil {
+			return nil, err
+		}
+		bindings = map[string]map[string]string{}
+		for exchange, routingKey := range cli.StringMap("exchange") {
+			if name, rest, found := strings.Cut(exchange, sourceSeparator); found && slices.ContainsFunc(all, func(s source) bool { return s.name == name }) {
+				if name != sourceName {
+					continue
+				}
+				exchange = rest
+			}
+			exchangeVhost := vhost
+			if i := strings.LastIndex(exchange, vhostSeparator); i >= 0 {
+				exchange, exchangeVhost = exchange[:i], exchange[i+len(vhostSeparator):]
+			}
+			if bindings[exchangeVhost] == nil {
+				bindings[exchangeVhost] = map[string]string{}
+			}
+			bindings[exchangeVhost][exchange] = routingKey
+		}
+		return bindings, nil
+	}
+	client, err := managementClient(cli, amqpUrl)
+	if err != nil {
+		return nil, err
+	}
+	exchanges, err := client.Exchanges(vhost)
+	if err != nil {
+		return nil, failed.Because("exchange is not set and exchanges could not be listed", err)
+	}
+	var names []string
+	for _, e := range exchanges {
+		// The default exchange cannot be bound to
+		if e.Name != "" {
+			names = append(names, e.Name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, failed.Because("no exchanges to listen to in vhost "+vhost, nil)
+	}
+	chosen, err := prompt.
+		New().
+		Ask(fmt.Sprintf("Choose exchanges to listen to in %s:", sourceName)).
+		MultiChoose(names)
+	if err != nil {
+		return nil, failed.Because("failed to prompt for exchanges", err)
+	}
+	if len(chosen) == 0 {
+		return nil, failed.Because("no exchange chosen", nil)
+	}
+	bindings = map[string]map[string]string{vhost: {}}
+	for _, exchange := range chosen {
+		bindings[vhost][exchange], err = prompt.
+			New().
+			Ask(fmt.Sprintf("Routing key for %s:", exchange)).
+			Input("#", input.WithCharLimit(0), input.WithEchoMode(input.EchoNormal))
+		if err != nil {
+			return nil, failed.Because("failed to prompt for routing key", err)
+		}
+	}
+	return bindings, nil
+}
+
+// ephemeralQueueName generates a unique name for an interceptor queue that is deleted with its connection.
+func ephemeralQueueName() string {
+	return fmt.Sprintf("%s.%s", "coyote", uuid.NewString())
+}
+
+// isInterceptorQueue tells whether a queue is an ephemeral interceptor queue of a coyote capture.
+func isInterceptorQueue(name string) bool {
+	return strings.HasPrefix(name, "coyote.")
+}
+
+// declareInterceptor declares the interceptor queue and binds it to every exchange and routing key pair.
+func declareInterceptor(ch *amqp091.Channel, queueName string, persistent bool, bindings map[string]string) (q amqp091.Queue, err error) {
+	q, err = ch.QueueDeclare(
+		queueName,   // queue name
+		false,       // is durable
+		!persistent, // is auto delete
+		!persistent, // is exclusive
+		false,       // is no wait
+		nil,         // args
+	)
+	if err != nil {
+		return q, failed.Because("failed to declare a queue:", err)
+	}
+
+	for exchange, routingKey := range bindings {
+		err = ch.ExchangeDeclarePassive(
+			exchange, // exchange name
+			"topic",  // exchange kind
+			true,     // is durable
+			false,    // is auto delete
+			false,    // is internal
+			false,    // is no wait
+			nil,      // args
+		)
+		if err != nil {
+			return q, failed.Because("failed to connect to exchange:", err)
+		}
+
+		err = ch.QueueBind(
+			q.Name,     // interceptor queue name
+			routingKey, // routing key to bind
+			exchange,   // exchange to listen
+			false,      // is no wait
+			nil,        // args
+		)
+		if err != nil {
+			return q, failed.Because("failed to bind to queue:", err)
+		} else {
+			log.Printf("👂 Listening from exchange %s with routing key %s using queue %s",
+				color.YellowString(exchange),
+				color.YellowString(routingKey),
+				color.YellowString(q.Name))
+		}
+	}
+	return q, nil
+}
+
+// interceptor is an interceptor queue consumed on a connection of its own, one for every captured vhost of a source.
+type interceptor struct {
+	origin     store.Origin
+	conn       *amqp091.Connection
+	ch         *amqp091.Channel
+	queue      amqp091.Queue
+	deliveries <-chan amqp091.Delivery
+	states     chan *amqp091.StateChanged
+}
+
+// captured is a delivery tagged with where it was captured.
+type captured struct {
+	amqp091.Delivery
+	origin store.Origin
+}
+
+// openInterceptor connects to the vhost of the origin, declares the interceptor queue with its bindings and starts consuming it.
+func openInterceptor(cli *cli.Command, amqpUrl *url.URL, origin store.Origin, queueName string, persistent bool, bindings map[string]string) (i *interceptor, err error) {
+	i = &interceptor{origin: origin}
+	i.conn, err = dialRecovering(cli, vhostUrl(amqpUrl, origin.Vhost))
+	if err != nil {
+		return nil, failed.Because("failed to connect to vhost "+origin.Vhost+" of "+origin.Source, err)
+	}
+	i.states = make(chan *amqp091.StateChanged, 1)
+	i.conn.NotifyStateChange(i.states)
+	log.Printf("🔌 Connected to vhost %s of %s", color.YellowString(origin.Vhost), color.YellowString(origin.Source))
+	i.ch, err = i.conn.Channel()
+	if err != nil {
+		i.close()
+		return nil, failed.Because("failed to open a channel:", err)
+	}
+	i.queue, err = declareInterceptor(i.ch, queueName, persistent, bindings)
+	// Messages piped to --exec are acknowledged once their command ran, with as many unacknowledged as it can take.
+	manualAck := cli.IsSet("exec")
+	if err == nil && manualAck {
+		if err = i.ch.Qos(execPrefetch(cli), 0, false); err != nil {
+			err = failed.Because("failed to set prefetch count:", err)
+		}
+	}
+	if err == nil {
+		i.deliveries, err = i.ch.Consume(
+			i.queue.Name, // queue name to consume from
+			i.queue.Name, // consumer tag
+			!manualAck,   // is auto ack
+			false,        // is exclusive
+			false,        // is no local
+			false,        // is no wait
+			nil,          // args
+		)
+		if err != nil {
+			err = failed.Because("failed to register a consumer:", err)
+		}
+	}
+	if err != nil {
+		i.close()
+		return nil, err
+	}
+	return i, nil
+}
+
+// cancel stops consuming, the deliveries channel is closed once the broker confirms.
+func (i *interceptor) cancel() {
+	if err := i.ch.Cancel(i.queue.Name, false); err != nil {
+		log.Printf("⚠️ Failed to cancel consumer in vhost %s of %s: %v", i.origin.Vhost, i.origin.Source, err)
+	}
+}
+
+// watch logs the connection being lost and recovered until it is closed. When recovery gives up, lost is called.
+func (i *interceptor) watch(lost func(err error)) {
+	for state := range i.states {
+		switch {
+		case state.To == amqp091.StateReconnecting:
+			log.Printf("🔁 Lost connection to vhost %s of %s, reconnecting", color.YellowString(i.origin.Vhost), color.YellowString(i.origin.Source))
+		case state.From == amqp091.StateReconnecting && state.To == amqp091.StateOpen:
+			metrics.Reconnects.WithLabelValues(i.origin.Source, i.origin.Vhost).Inc()
+			log.Printf("🔌 Reconnected to vhost %s of %s", color.YellowString(i.origin.Vhost), color.YellowString(i.origin.Source))
+			for _, skipped := range state.SkippedTopologyEntities {
+				log.Printf("⚠️ Failed to recover %v", skipped)
+			}
+		case state.To == amqp091.StateClosed:
+			if state.Err != nil {
+				lost(failed.Because("lost connection to vhost "+i.origin.Vhost+" of "+i.origin.Source, state.Err))
+			}
+			return
+		}
+	}
+}
+
+func (i *interceptor) close() {
+	if i.ch != nil {
+		if err := i.ch.Close(); err != nil && !errors.Is(err, amqp091.ErrClosed) {
+			log.Fatal(err)
+		}
+		log.Printf("⛓️‍💥 Terminating AMQP channel")
+	}
+	if err := i.conn.Close(); err != nil && !errors.Is(err, amqp091.ErrClosed) {
+		log.Fatal(err)
+	}
+	log.Printf("⛓️‍💥 Terminating AMQP connection")
+}
+
+// mergeDeliveries tags the deliveries of every interceptor with its origin and merges them into a single channel,
+// which is closed once all of them are.
+func mergeDeliveries(interceptors []*interceptor) <-chan captured {
+	merged := make(chan captured)
+	var wg sync.WaitGroup
+	for _, i := range interceptors {
+		wg.Go(func() {
+			for d := range i.deliveries {
+				merged <- captured{Delivery: d, origin: i.origin}
+			}
+		})
+	}
+	go func() {
+		wg.Wait()
+		close(merged)
+	}()
+	return merged
+}
@@ -0,0 +1,55 @@
+package jsonpath
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// Decode parses a JSON document keeping numbers as json.Number so they can be compared and re-encoded verbatim.
+func Decode(body []byte) (doc any, err error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	err = decoder.Decode(&doc)
+	return doc, err
+}
+
+// Lookup returns the value at a dot separated path such as order.items.0.sku.
+// Array elements are addressed by their index. An empty path returns the document itself.
+func Lookup(doc any, path string) (value any, found bool) {
+	value = doc
+	if path == "" {
+		return value, true
+	}
+	for _, segment := range strings.Split(path, ".") {
+		switch node := value.(type) {
+		case map[string]any:
+			value, found = node[segment]
+			if !found {
+				return nil, false
+			}
+		case []any:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, false
+			}
+			value = node[index]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// String renders a JSON value for comparison, strings are returned without quotes.
+func String(value any) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	return string(encoded)
+}
@@ -0,0 +1,78 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	doc, err := Decode([]byte(`{"order":{"id":42,"total":19.90,"items":[{"sku":"a","tags":["new"]},{"sku":"b"}],"note":null},
+		"a.b":1,"":"empty","big":12345678901234567890}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path     string
+		expected string
+		found    bool
+	}{
+		{"order.id", "42", true},
+		{"order.total", "19.90", true},
+		{"big", "12345678901234567890", true},
+		{"order.items.0.sku", "a", true},
+		{"order.items.1.sku", "b", true},
+		{"order.items.0.tags.0", "new", true},
+		{"order.items.0", `{"sku":"a","tags":["new"]}`, true},
+		{"order.note", "null", true},
+		{"order.items.2.sku", "", false},
+		{"order.items.-1", "", false},
+		{"order.items.first", "", false},
+		{"order.id.value", "", false},
+		{"order.missing", "", false},
+		{"missing.id", "", false},
+		{"a.b", "", false},
+		{"order..id", "", false},
+	}
+	for _, test := range tests {
+		value, found := Lookup(doc, test.path)
+		if found != test.found || (found && String(value) != test.expected) {
+			t.Errorf("%s: expected %q and found %t, got %q and %t", test.path, test.expected, test.found, String(value), found)
+		}
+	}
+	if value, found := Lookup(doc, ""); !found || value == nil {
+		t.Error("expected an empty path to return the document")
+	}
+}
+
+func TestDecode(t *testing.T) {
+	doc, err := Decode([]byte(`[1, 2.50]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values, ok := doc.([]any); !ok || len(values) != 2 || values[1] != json.Number("2.50") {
+		t.Errorf("expected numbers to be kept verbatim, got %#v", doc)
+	}
+	if _, err := Decode([]byte(`{"id":`)); err == nil {
+		t.Error("expected truncated JSON to fail")
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		value    any
+		expected string
+	}{
+		{"text", "text"},
+		{json.Number("42"), "42"},
+		{true, "true"},
+		{nil, "null"},
+		{map[string]any{"b": 1, "a": "x"}, `{"a":"x","b":1}`},
+		{[]any{"a", 1}, `["a",1]`},
+		{func() {}, ""},
+	}
+	for _, test := range tests {
+		if s := String(test.value); s != test.expected {
+			t.Errorf("expected %#v to be rendered as %q, got %q", test.value, test.expected, s)
+		}
+	}
+}
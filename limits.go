@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/fatih/color"
+	failed "github.com/ghokun/coyote/error"
+	"github.com/ghokun/coyote/sampling"
+	"github.com/urfave/cli/v3"
+)
+
+// Exit codes of a capture that stopped by itself. Errors exit with 1 and a forced shutdown exits with 2.
+const (
+	exitLimitReached    = 0
+	exitDurationElapsed = 3
+	exitIdleTimeout     = 4
+	exitNoMessages      = 5
+)
+
+// stopReason tells why a capture ended.
+type stopReason int
+
+const (
+	stopInterrupted stopReason = iota
+	stopLimitReached
+	stopDurationElapsed
+	stopIdleTimeout
+	stopConnectionLost
+	stopExecFailed
+)
+
+func (r stopReason) String() string {
+	switch r {
+	case stopLimitReached:
+		return "limit reached"
+	case stopDurationElapsed:
+		return "duration elapsed"
+	case stopIdleTimeout:
+		return "idle timeout"
+	case stopConnectionLost:
+		return "connection lost"
+	case stopExecFailed:
+		return "exec failed"
+	default:
+		return "interrupted"
+	}
+}
+
+type limits struct {
+	maxMessages int
+	maxBytes    int64
+	duration    time.Duration
+	idleTimeout time.Duration
+}
+
+func newLimits(cli *cli.Command) limits {
+	return limits{
+		maxMessages: cli.Int("max-messages"),
+		maxBytes:    cli.Int64("max-bytes"),
+		duration:    cli.Duration("duration"),
+		idleTimeout: cli.Duration("idle-timeout"),
+	}
+}
+
+// captureSampler returns the sampler of --sample, --sample-every, --sample-key and --rate-limit, or nil when every
+// message is kept.
+func captureSampler(cli *cli.Command) (*sampling.Sampler, error) {
+	options := sampling.Options{
+		Rate:      cli.Float("sample"),
+		Every:     cli.Int("sample-every"),
+		PerSecond: cli.Float("rate-limit"),
+	}
+	for _, text := range cli.StringSlice("sample-key") {
+		key, err := sampling.ParseKeyRate(text)
+		if err != nil {
+			return nil, err
+		}
+		options.Keys = append(options.Keys, key)
+	}
+	sampler, err := sampling.New(options)
+	if err != nil {
+		return nil, err
+	}
+	if sampler != nil {
+		log.Printf("🎲 Sampling messages at %s", color.YellowString(sampler.String()))
+	}
+	return sampler, nil
+}
+
+func (l limits) reached(count int, bytes int64) bool {
+	return (l.maxMessages > 0 && count >= l.maxMessages) || (l.maxBytes > 0 && bytes >= l.maxBytes)
+}
+
+// wait blocks until the capture is interrupted, a limit is reached, or one of the timers fires.
+// Every receive from activity restarts the idle timer.
+func (l limits) wait(ctx context.Context, stopped <-chan stopReason, activity <-chan struct{}) stopReason {
+	var elapsed, idle <-chan time.Time
+	if l.duration > 0 {
+		timer := time.NewTimer(l.duration)
+		defer timer.Stop()
+		elapsed = timer.C
+	}
+	var idleTimer *time.Timer
+	if l.idleTimeout > 0 {
+		idleTimer = time.NewTimer(l.idleTimeout)
+		defer idleTimer.Stop()
+		idle = idleTimer.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return stopInterrupted
+		case reason := <-stopped:
+			return reason
+		case <-elapsed:
+			return stopDurationElapsed
+		case <-idle:
+			return stopIdleTimeout
+		case <-activity:
+			if idleTimer != nil {
+				idleTimer.Reset(l.idleTimeout)
+			}
+		}
+	}
+}
+
+// exit reports why the capture stopped and converts the reason into an exit code.
+func (l limits) exit(reason stopReason, count int) error {
+	switch reason {
+	case stopInterrupted:
+		return nil
+	case stopConnectionLost:
+		return failed.Because(fmt.Sprintf("capture stopped after %d messages", count), nil)
+	case stopExecFailed:
+		return failed.Because(fmt.Sprintf("capture stopped after %d messages, an exec command failed", count), nil)
+	case stopLimitReached:
+		log.Printf("🏁 Capture limit reached after %s messages", color.GreenString("%d", count))
+	case stopDurationElapsed:
+		log.Printf("⏰ Capture duration of %s elapsed", color.YellowString(l.duration.String()))
+	case stopIdleTimeout:
+		log.Printf("💤 No messages received for %s", color.YellowString(l.idleTimeout.String()))
+	}
+	if count == 0 {
+		log.Printf("🫙 No messages were captured")
+		return cli.Exit("", exitNoMessages)
+	}
+	switch reason {
+	case stopDurationElapsed:
+		return cli.Exit("", exitDurationElapsed)
+	case stopIdleTimeout:
+		return cli.Exit("", exitIdleTimeout)
+	default:
+		return cli.Exit("", exitLimitReached)
+	}
+}
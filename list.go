@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/ghokun/coyote/management"
+	"github.com/urfave/cli/v3"
+)
+
+func listCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "list",
+		Usage: "Lists vhosts, exchanges, queues and bindings through the management API.",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "vhost",
+				Usage: "Vhost to list, the vhost of --url if omitted.",
+			},
+		},
+		Commands: []*cli.Command{
+			{
+				Name:  "vhosts",
+				Usage: "Lists vhosts.",
+				Action: func(ctx context.Context, cli *cli.Command) error {
+					client, _, err := listClient(cli)
+					if err != nil {
+						return err
+					}
+					vhosts, err := client.Vhosts()
+					if err != nil {
+						return err
+					}
+					return printTable(cli, []string{"NAME"}, func(row func(...string)) {
+						for _, vhost := range vhosts {
+							row(vhost.Name)
+						}
+					})
+				},
+			},
+			{
+				Name:  "exchanges",
+				Usage: "Lists exchanges.",
+				Action: func(ctx context.Context, cli *cli.Command) error {
+					client, vhost, err := listClient(cli)
+					if err != nil {
+						return err
+					}
+					exchanges, err := client.Exchanges(vhost)
+					if err != nil {
+						return err
+					}
+					return printTable(cli, []string{"NAME", "TYPE", "DURABLE", "AUTO-DELETE", "INTERNAL"}, func(row func(...string)) {
+						for _, e := range exchanges {
+							row(displayExchange(e.Name), e.Type, strconv.FormatBool(e.Durable), strconv.FormatBool(e.AutoDelete), strconv.FormatBool(e.Internal))
+						}
+					})
+				},
+			},
+			{
+				Name:  "queues",
+				Usage: "Lists queues.",
+				Action: func(ctx context.Context, cli *cli.Command) error {
+					client, vhost, err := listClient(cli)
+					if err != nil {
+						return err
+					}
+					queues, err := client.Queues(vhost)
+					if err != nil {
+						return err
+					}
+					return printTable(cli, []string{"NAME", "MESSAGES", "DURABLE", "AUTO-DELETE", "EXCLUSIVE"}, func(row func(...string)) {
+						for _, q := range queues {
+							row(q.Name, strconv.Itoa(q.Messages), strconv.FormatBool(q.Durable), strconv.FormatBool(q.AutoDelete), strconv.FormatBool(q.Exclusive))
+						}
+					})
+				},
+			},
+			{
+				Name:  "bindings",
+				Usage: "Lists bindings.",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "exchange",
+						Usage: "Lists only the bindings of the given source exchange.",
+					},
+				},
+				Action: func(ctx context.Context, cli *cli.Command) error {
+					client, vhost, err := listClient(cli)
+					if err != nil {
+						return err
+					}
+					bindings, err := client.Bindings(vhost)
+					if err != nil {
+						return err
+					}
+					return printTable(cli, []string{"SOURCE", "DESTINATION", "DESTINATION-TYPE", "ROUTING-KEY"}, func(row func(...string)) {
+						for _, b := range bindings {
+							if cli.IsSet("exchange") && b.Source != cli.String("exchange") {
+								continue
+							}
+							row(displayExchange(b.Source), b.Destination, b.DestinationType, b.RoutingKey)
+						}
+					})
+				},
+			},
+		},
+	}
+}
+
+// listClient authenticates and returns a management API client together with the vhost to list.
+func listClient(cli *cli.Command) (client *management.Client, vhost string, err error) {
+	amqpUrl, err := authenticate(cli)
+	if err != nil {
+		return nil, "", err
+	}
+	client, err = managementClient(cli, amqpUrl)
+	if err != nil {
+		return nil, "", err
+	}
+	vhost = cli.String("vhost")
+	if vhost == "" {
+		vhost = management.DefaultVhost(amqpUrl)
+	}
+	return client, vhost, nil
+}
+
+// displayExchange names the default exchange the way the management UI does.
+func displayExchange(name string) string {
+	if name == "" {
+		return "(AMQP default)"
+	}
+	return name
+}
+
+// printTable writes aligned columns to standard output, so that listings can be piped to other tools.
+func printTable(cli *cli.Command, header []string, rows func(row func(...string))) error {
+	w := tabwriter.NewWriter(cli.Root().Writer, 0, 0, 2, ' ', 0)
+	row := func(columns ...string) {
+		for i, column := range columns {
+			if i > 0 {
+				_, _ = fmt.Fprint(w, "\t")
+			}
+			_, _ = fmt.Fprint(w, column)
+		}
+		_, _ = fmt.Fprintln(w)
+	}
+	row(header...)
+	rows(row)
+	return w.Flush()
+}
+
+// completeExchanges completes the value of --exchange with the exchange names of the vhost of the first --url.
+// Completions cannot prompt, so they are only offered when --url carries the credentials.
+func completeExchanges(ctx context.Context, cmd *cli.Command) {
+	if len(os.Args) < 3 || os.Args[len(os.Args)-2] != "--exchange" {
+		cli.DefaultCompleteWithFlags(ctx, cmd)
+		return
+	}
+	urls := cmd.StringSlice("url")
+	if len(urls) == 0 {
+		return
+	}
+	amqpUrl, err := url.Parse(parseSource(urls[0]).rawUrl)
+	if err != nil || amqpUrl.User == nil {
+		return
+	}
+	client, err := managementClient(cmd, amqpUrl)
+	if err != nil {
+		return
+	}
+	exchanges, err := client.Exchanges(management.DefaultVhost(amqpUrl))
+	if err != nil {
+		return
+	}
+	for _, e := range exchanges {
+		if e.Name != "" {
+			_, _ = fmt.Fprintln(cmd.Root().Writer, e.Name+"=")
+		}
+	}
+}
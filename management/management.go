@@ -0,0 +1,207 @@
+package management
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	failed "github.com/ghokun/coyote/error"
+	"github.com/rabbitmq/amqp091-go"
+)
+
+type Vhost struct {
+	Name string `json:"name"`
+}
+
+type Exchange struct {
+	Name       string         `json:"name"`
+	Vhost      string         `json:"vhost"`
+	Type       string         `json:"type"`
+	Durable    bool           `json:"durable"`
+	AutoDelete bool           `json:"auto_delete"`
+	Internal   bool           `json:"internal"`
+	Arguments  map[string]any `json:"arguments"`
+}
+
+type Queue struct {
+	Name       string         `json:"name"`
+	Vhost      string         `json:"vhost"`
+	Durable    bool           `json:"durable"`
+	AutoDelete bool           `json:"auto_delete"`
+	Exclusive  bool           `json:"exclusive"`
+	Arguments  map[string]any `json:"arguments"`
+	Messages   int            `json:"messages"`
+}
+
+type Binding struct {
+	Source          string         `json:"source"`
+	Vhost           string         `json:"vhost"`
+	Destination     string         `json:"destination"`
+	DestinationType string         `json:"destination_type"`
+	RoutingKey      string         `json:"routing_key"`
+	Arguments       map[string]any `json:"arguments"`
+}
+
+// Definitions is the topology of a vhost, in the shape of a definitions export.
+type Definitions struct {
+	Exchanges []Exchange `json:"exchanges"`
+	Queues    []Queue    `json:"queues"`
+	Bindings  []Binding  `json:"bindings"`
+}
+
+// Client calls the RabbitMQ management HTTP API with the credentials used for AMQP.
+type Client struct {
+	api      *url.URL
+	username string
+	password string
+	bearer   bool
+	http     *http.Client
+}
+
+// APIURL returns the management API url. Unless given explicitly, it is served over http(s) on the host of the AMQP url.
+func APIURL(amqpUrl *url.URL, managementUrl string) (apiUrl *url.URL, err error) {
+	if managementUrl != "" {
+		apiUrl, err = url.Parse(managementUrl)
+		if err != nil {
+			return nil, failed.Because("failed to parse management url", err)
+		}
+		return apiUrl, nil
+	}
+	apiScheme := "http"
+	if amqpUrl.Scheme == "amqps" {
+		apiScheme = "https"
+	}
+	return &url.URL{Scheme: apiScheme, Host: amqpUrl.Host, Path: "/api"}, nil
+}
+
+// New creates a client authenticating with the user of the AMQP url. With bearer set, the password is
+// an OAuth 2.0 access token, as it is when connecting with OAuth 2.0.
+func New(apiUrl *url.URL, amqpUrl *url.URL, bearer bool, insecure bool) *Client {
+	c := &Client{
+		api:    apiUrl,
+		bearer: bearer,
+		http: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: insecure}},
+		},
+	}
+	if amqpUrl.User != nil {
+		c.username = amqpUrl.User.Username()
+		c.password, _ = amqpUrl.User.Password()
+	}
+	return c
+}
+
+// DefaultVhost returns the vhost selected by the path of the AMQP url.
+func DefaultVhost(amqpUrl *url.URL) string {
+	uri, err := amqp091.ParseURI(amqpUrl.String())
+	if err != nil {
+		return "/"
+	}
+	return uri.Vhost
+}
+
+func (c *Client) Vhosts() (vhosts []Vhost, err error) {
+	return vhosts, c.get(&vhosts, "vhosts")
+}
+
+func (c *Client) Exchanges(vhost string) (exchanges []Exchange, err error) {
+	return exchanges, c.get(&exchanges, "exchanges", vhost)
+}
+
+func (c *Client) Queues(vhost string) (queues []Queue, err error) {
+	return queues, c.get(&queues, "queues", vhost)
+}
+
+func (c *Client) Bindings(vhost string) (bindings []Binding, err error) {
+	return bindings, c.get(&bindings, "bindings", vhost)
+}
+
+// Definitions returns the exchanges, queues and bindings of a vhost. They are listed one by one instead of
+// through a definitions export, since exports leave out exclusive queues such as coyote's interceptor queues.
+func (c *Client) Definitions(vhost string) (definitions *Definitions, err error) {
+	definitions = &Definitions{}
+	if definitions.Exchanges, err = c.Exchanges(vhost); err != nil {
+		return nil, err
+	}
+	if definitions.Queues, err = c.Queues(vhost); err != nil {
+		return nil, err
+	}
+	if definitions.Bindings, err = c.Bindings(vhost); err != nil {
+		return nil, err
+	}
+	return definitions, nil
+}
+
+// ReadDefinitions reads a definitions export of a vhost or of the whole broker, keeping what belongs to the vhost.
+// Exports of a single vhost do not name the vhost of their entries, so those are always kept.
+func ReadDefinitions(filename string, vhost string) (definitions *Definitions, err error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, failed.Because("failed to read definitions", err)
+	}
+	var all Definitions
+	if err := json.Unmarshal(content, &all); err != nil {
+		return nil, failed.Because("failed to decode definitions in "+filename, err)
+	}
+	definitions = &Definitions{}
+	for _, e := range all.Exchanges {
+		if e.Vhost == "" || e.Vhost == vhost {
+			definitions.Exchanges = append(definitions.Exchanges, e)
+		}
+	}
+	for _, q := range all.Queues {
+		if q.Vhost == "" || q.Vhost == vhost {
+			definitions.Queues = append(definitions.Queues, q)
+		}
+	}
+	for _, b := range all.Bindings {
+		if b.Vhost == "" || b.Vhost == vhost {
+			definitions.Bindings = append(definitions.Bindings, b)
+		}
+	}
+	return definitions, nil
+}
+
+// get decodes the response of the endpoint made of the given path segments, which are escaped one by one
+// since the default vhost is named /.
+func (c *Client) get(target any, segments ...string) error {
+	endpoint := *c.api
+	endpoint.RawPath = strings.TrimSuffix(c.api.EscapedPath(), "/")
+	for _, segment := range segments {
+		endpoint.RawPath += "/" + url.PathEscape(segment)
+	}
+	endpoint.Path, _ = url.PathUnescape(endpoint.RawPath)
+	req, err := http.NewRequest(http.MethodGet, endpoint.String(), nil)
+	if err != nil {
+		return failed.Because("failed to create management request", err)
+	}
+	if c.bearer {
+		req.Header.Set("Authorization", "Bearer "+c.password)
+	} else {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return failed.Because("failure while connecting to "+endpoint.Redacted(), err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Fatal(failed.Because("failed to close management response body", err))
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return failed.Because("failed to fetch "+endpoint.Redacted()+", status code: "+resp.Status, nil)
+	}
+	err = json.NewDecoder(resp.Body).Decode(target)
+	if err != nil && err != io.EOF {
+		return failed.Because("failed to decode "+endpoint.Redacted(), err)
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	failed "github.com/ghokun/coyote/error"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rabbitmq/amqp091-go"
+)
+
+const namespace = "coyote"
+
+var (
+	MessagesReceived = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_received_total",
+		Help:      "Number of messages received by the interceptor queue.",
+	}, []string{"source", "vhost", "exchange", "routing_key"})
+
+	BytesReceived = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "received_bytes_total",
+		Help:      "Number of message body bytes received by the interceptor queue.",
+	}, []string{"source", "vhost", "exchange", "routing_key"})
+
+	MessagesSkipped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_skipped_total",
+		Help:      "Number of received messages skipped by sampling or rate limiting instead of being printed or stored.",
+	}, []string{"source", "vhost", "exchange", "routing_key"})
+
+	MessagesInvalid = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_invalid_total",
+		Help:      "Number of received messages violating the contract of their exchange and routing key.",
+	}, []string{"source", "vhost", "exchange", "routing_key"})
+
+	StoreWriteSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "store_write_duration_seconds",
+		Help:      "Latency of writing a single message into the SQLite store.",
+		Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 8),
+	})
+
+	StoreErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "store_errors_total",
+		Help:      "Number of messages that could not be written into the SQLite store.",
+	})
+
+	ExecFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "exec_failures_total",
+		Help:      "Number of --exec commands that failed after their retries.",
+	})
+
+	ConsumerCancellations = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "consumer_cancellations_total",
+		Help:      "Number of times the broker cancelled the interceptor consumer.",
+	})
+
+	Reconnects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reconnects_total",
+		Help:      "Number of times a lost interceptor connection was recovered.",
+	}, []string{"source", "vhost"})
+
+	QueueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "queue_messages",
+		Help:      "Number of ready messages in the interceptor queue, sampled periodically.",
+	}, []string{"source", "vhost"})
+)
+
+// queueDepthInterval is how often the interceptor queue is passively declared to sample its depth.
+const queueDepthInterval = 10 * time.Second
+
+var registry = prometheus.NewRegistry()
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		MessagesReceived,
+		BytesReceived,
+		MessagesSkipped,
+		MessagesInvalid,
+		StoreWriteSeconds,
+		StoreErrors,
+		ExecFailures,
+		ConsumerCancellations,
+		Reconnects,
+		QueueDepth,
+	)
+}
+
+// Serve exposes the collected metrics in Prometheus text format on /metrics at the given address.
+func Serve(addr string) (server *http.Server, err error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, failed.Because("failed to listen for metrics on "+addr, err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	server = &http.Server{Handler: mux}
+	go func() {
+		if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Metrics server error: %v", err)
+		}
+	}()
+	return server, nil
+}
+
+// SampleQueueDepth periodically declares the queue passively and records its message count until ctx is done.
+// A dedicated channel should be used, since a failed passive declare closes the channel it was issued on.
+func SampleQueueDepth(ctx context.Context, ch *amqp091.Channel, source string, vhost string, queueName string) {
+	ticker := time.NewTicker(queueDepthInterval)
+	defer ticker.Stop()
+	for {
+		q, err := ch.QueueDeclarePassive(
+			queueName, // queue name
+			false,     // is durable
+			false,     // is auto delete
+			false,     // is exclusive
+			false,     // is no wait
+			nil,       // args
+		)
+		if err != nil {
+			log.Printf("⚠️ Failed to sample interceptor queue depth in vhost %s of %s: %v", vhost, source, err)
+			return
+		}
+		QueueDepth.WithLabelValues(source, vhost).Set(float64(q.Messages))
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
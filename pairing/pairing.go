@@ -0,0 +1,120 @@
+package pairing
+
+import (
+	"slices"
+	"time"
+
+	"github.com/ghokun/coyote/stats"
+)
+
+// Message holds the fields of a captured message that take part in request/reply pairing.
+type Message struct {
+	Time          time.Time
+	Exchange      string
+	RoutingKey    string
+	CorrelationId string
+	ReplyTo       string
+}
+
+// Pair is a request together with the reply that carries its correlation id.
+type Pair struct {
+	Request Message
+	Reply   Message
+}
+
+func (p Pair) Latency() time.Duration {
+	return p.Reply.Time.Sub(p.Request.Time)
+}
+
+// Pairer matches replies to requests by correlation id. A message with a reply-to is a request,
+// a message whose routing key is the reply-to of a pending request with the same correlation id is its reply.
+// Messages without a correlation id are not part of any exchange and are ignored.
+type Pairer struct {
+	pending   map[string]Message
+	latencies map[string][]time.Duration
+	unmatched int
+	orphaned  int
+}
+
+func New() *Pairer {
+	return &Pairer{
+		pending:   map[string]Message{},
+		latencies: map[string][]time.Duration{},
+	}
+}
+
+// Result tells what a message turned out to be when it was added.
+type Result int
+
+const (
+	Ignored Result = iota
+	Request
+	Reply
+	Unmatched
+)
+
+// Add records a message, returning the completed pair when the message is a reply. A request reusing the correlation
+// id of a pending request replaces it, which is then orphaned and returned as the request of the pair.
+func (p *Pairer) Add(m Message) (Result, Pair) {
+	if m.CorrelationId == "" {
+		return Ignored, Pair{}
+	}
+	if request, found := p.pending[m.CorrelationId]; found && request.ReplyTo == m.RoutingKey {
+		delete(p.pending, m.CorrelationId)
+		pair := Pair{Request: request, Reply: m}
+		p.latencies[request.RoutingKey] = append(p.latencies[request.RoutingKey], pair.Latency())
+		return Reply, pair
+	}
+	if m.ReplyTo != "" {
+		replaced, found := p.pending[m.CorrelationId]
+		p.pending[m.CorrelationId] = m
+		if found {
+			p.orphaned++
+			return Request, Pair{Request: replaced}
+		}
+		return Request, Pair{}
+	}
+	p.unmatched++
+	return Unmatched, Pair{}
+}
+
+// OrphanedRequests returns the number of requests that were replaced, expired or collected without a reply.
+func (p *Pairer) OrphanedRequests() int {
+	return p.orphaned
+}
+
+// UnmatchedReplies returns the number of replies that matched no request.
+func (p *Pairer) UnmatchedReplies() int {
+	return p.unmatched
+}
+
+// Expire removes and returns the pending requests sent before the given time, in the order they were sent.
+func (p *Pairer) Expire(before time.Time) []Message {
+	return p.take(func(request Message) bool { return request.Time.Before(before) })
+}
+
+// Orphans removes and returns every request that has not been replied to, in the order they were sent.
+func (p *Pairer) Orphans() []Message {
+	return p.take(func(Message) bool { return true })
+}
+
+func (p *Pairer) take(selected func(request Message) bool) (requests []Message) {
+	for correlationId, request := range p.pending {
+		if selected(request) {
+			requests = append(requests, request)
+			delete(p.pending, correlationId)
+		}
+	}
+	slices.SortFunc(requests, func(a, b Message) int { return a.Time.Compare(b.Time) })
+	p.orphaned += len(requests)
+	return requests
+}
+
+// Summary returns the latency distribution of the replied requests per request routing key.
+func (p *Pairer) Summary() map[string]stats.Summary[time.Duration] {
+	summaries := map[string]stats.Summary[time.Duration]{}
+	for routingKey, latencies := range p.latencies {
+		summaries[routingKey] = stats.Summarize(slices.Clone(latencies))
+	}
+	return summaries
+}
@@ -0,0 +1,72 @@
+package pairing
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPairer(t *testing.T) {
+	start := time.Now()
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+	pairer := New()
+	tests := []struct {
+		message  Message
+		expected Result
+	}{
+		{Message{Time: at(0), RoutingKey: "price.quote", CorrelationId: "1", ReplyTo: "replies"}, Request},
+		{Message{Time: at(1), RoutingKey: "price.quote", CorrelationId: "2", ReplyTo: "replies"}, Request},
+		{Message{Time: at(2), RoutingKey: "order.created"}, Ignored},
+		{Message{Time: at(3), RoutingKey: "other", CorrelationId: "1"}, Unmatched},
+		{Message{Time: at(10), RoutingKey: "replies", CorrelationId: "1"}, Reply},
+		{Message{Time: at(11), RoutingKey: "replies", CorrelationId: "1"}, Unmatched},
+		{Message{Time: at(12), RoutingKey: "stock.check", CorrelationId: "3", ReplyTo: "replies"}, Request},
+	}
+	for i, test := range tests {
+		result, pair := pairer.Add(test.message)
+		if result != test.expected {
+			t.Fatalf("message %d: expected result %v, got %v", i, test.expected, result)
+		}
+		if result == Reply && pair.Latency() != 10*time.Millisecond {
+			t.Errorf("message %d: expected latency 10ms, got %s", i, pair.Latency())
+		}
+	}
+
+	expired := pairer.Expire(at(5))
+	if len(expired) != 1 || expired[0].CorrelationId != "2" {
+		t.Errorf("expected request 2 to expire, got %v", expired)
+	}
+	orphans := pairer.Orphans()
+	if len(orphans) != 1 || orphans[0].CorrelationId != "3" {
+		t.Errorf("expected request 3 to be orphaned, got %v", orphans)
+	}
+	if pairer.OrphanedRequests() != 2 || pairer.UnmatchedReplies() != 2 {
+		t.Errorf("expected 2 orphaned requests and 2 unmatched replies, got %d and %d", pairer.OrphanedRequests(), pairer.UnmatchedReplies())
+	}
+	summary := pairer.Summary()
+	if len(summary) != 1 || summary["price.quote"].Count != 1 || summary["price.quote"].Max != 10*time.Millisecond {
+		t.Errorf("unexpected summary %v", summary)
+	}
+}
+
+func TestReusedCorrelationId(t *testing.T) {
+	start := time.Now()
+	pairer := New()
+	first := Message{Time: start, RoutingKey: "price.quote", CorrelationId: "1", ReplyTo: "replies"}
+	second := Message{Time: start.Add(5 * time.Millisecond), RoutingKey: "price.quote", CorrelationId: "1", ReplyTo: "replies"}
+	if result, pair := pairer.Add(first); result != Request || pair.Request.CorrelationId != "" {
+		t.Fatalf("expected a request replacing none, got %v replacing %v", result, pair.Request)
+	}
+	if result, pair := pairer.Add(second); result != Request || pair.Request != first {
+		t.Fatalf("expected a request replacing the first one, got %v replacing %v", result, pair.Request)
+	}
+	if pairer.OrphanedRequests() != 1 {
+		t.Errorf("expected the replaced request to be orphaned, got %d orphaned requests", pairer.OrphanedRequests())
+	}
+	result, pair := pairer.Add(Message{Time: start.Add(8 * time.Millisecond), RoutingKey: "replies", CorrelationId: "1"})
+	if result != Reply || pair.Request != second || pair.Latency() != 3*time.Millisecond {
+		t.Errorf("expected the reply to pair with the second request in 3ms, got %v with %v", result, pair)
+	}
+	if orphans := pairer.Orphans(); len(orphans) != 0 || pairer.OrphanedRequests() != 1 {
+		t.Errorf("expected no more orphans, got %v and %d orphaned requests", orphans, pairer.OrphanedRequests())
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	failed "github.com/ghokun/coyote/error"
+	"github.com/ghokun/coyote/pairing"
+	"github.com/ghokun/coyote/store"
+	"github.com/urfave/cli/v3"
+)
+
+const pairsUsage = `coyote pairs [options]
+
+Pairs requests and replies of a stored capture by correlation id. A message with a reply-to is a request,
+a message with the same correlation id whose routing key is the reply-to of the request is its reply.
+
+Examples:
+# List request/reply pairs with their latencies, orphaned requests and unmatched replies
+coyote pairs --store events.sqlite
+
+# Report RPC latency percentiles per routing key
+coyote pairs --store events.sqlite --summary`
+
+func pairsCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "pairs",
+		Usage:     "Pairs requests and replies of a stored capture by correlation id.",
+		UsageText: pairsUsage,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "store",
+				Usage: "SQLite filename to read events from.",
+			},
+			&cli.BoolFlag{
+				Name:  "summary",
+				Usage: "Prints only the latency summary per routing key.",
+			},
+		},
+		Action: pairs,
+	}
+}
+
+func pairs(_ context.Context, cli *cli.Command) error {
+	if !cli.IsSet("store") {
+		return failed.Because("store must be set", nil)
+	}
+	st, err := openExistingStore(cli, cli.String("store"))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := st.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	pairer := pairing.New()
+	verbose := !cli.Bool("summary")
+	err = st.Events(store.Filter{}, func(e store.Event) error {
+		observePair(pairer, pairing.Message{
+			Time:          e.Timestamp,
+			Exchange:      e.Exchange,
+			RoutingKey:    e.RoutingKey,
+			CorrelationId: e.CorrelationId,
+			ReplyTo:       e.ReplyTo,
+		}, verbose)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	reportPairs(pairer, verbose)
+	return nil
+}
+
+// observePair adds a message to the pairer, printing completed pairs, replaced requests and unmatched replies when
+// verbose.
+func observePair(pairer *pairing.Pairer, m pairing.Message, verbose bool) {
+	result, pair := pairer.Add(m)
+	if !verbose {
+		return
+	}
+	switch result {
+	case pairing.Reply:
+		log.Printf("🔗 %s → %s in %s (correlation id %s)",
+			color.YellowString(pair.Request.RoutingKey),
+			color.YellowString(pair.Reply.RoutingKey),
+			color.GreenString(pair.Latency().String()),
+			pair.Request.CorrelationId)
+	case pairing.Request:
+		if pair.Request.CorrelationId != "" {
+			printOrphanedRequest(pair.Request)
+		}
+	case pairing.Unmatched:
+		printUnmatchedReply(m)
+	}
+}
+
+func printOrphanedRequest(request pairing.Message) {
+	log.Printf("⚠️ Request %s sent at %s got no reply (correlation id %s)",
+		color.YellowString(request.RoutingKey),
+		request.Time.Format(time.DateTime),
+		request.CorrelationId)
+}
+
+func printUnmatchedReply(reply pairing.Message) {
+	log.Printf("⚠️ Reply %s matches no request (correlation id %s)",
+		color.YellowString(reply.RoutingKey),
+		reply.CorrelationId)
+}
+
+// reportPairs prints the requests that were never replied to and the latency summary per routing key.
+func reportPairs(pairer *pairing.Pairer, verbose bool) {
+	orphans := pairer.Orphans()
+	if verbose {
+		for _, request := range orphans {
+			printOrphanedRequest(request)
+		}
+	}
+	summaries := pairer.Summary()
+	var lines []string
+	for _, routingKey := range slices.Sorted(maps.Keys(summaries)) {
+		s := summaries[routingKey]
+		lines = append(lines, color.GreenString("# %s", routingKey)+
+			"\n  replies "+color.YellowString("%d", s.Count)+
+			", min "+s.Min.String()+", p50 "+s.P50.String()+", p90 "+s.P90.String()+
+			", p99 "+s.P99.String()+", max "+s.Max.String())
+	}
+	log.Printf("⏱️ %s, %s orphaned requests, %s unmatched replies\n%s",
+		color.YellowString("RPC latency per routing key"),
+		color.YellowString("%d", pairer.OrphanedRequests()),
+		color.YellowString("%d", pairer.UnmatchedReplies()),
+		strings.Join(lines, "\n"))
+}
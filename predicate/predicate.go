@@ -0,0 +1,126 @@
+package predicate
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	failed "github.com/ghokun/coyote/error"
+	"github.com/ghokun/coyote/jsonpath"
+	"github.com/ghokun/coyote/render"
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// Separator joins the conditions of a predicate, all of which must hold for a message to match.
+const Separator = ";"
+
+type condition struct {
+	field  string
+	value  string
+	regexp *regexp.Regexp
+}
+
+// Predicate matches messages by their exchange, routing key, properties, headers and JSON body fields.
+// It is written as conditions joined by Separator, each either field=value for equality or field~regexp,
+// e.g. routing-key=order.created;header.tenant=acme;body.order.id~^4[0-9]$
+type Predicate struct {
+	text       string
+	conditions []condition
+}
+
+func Parse(text string) (predicate *Predicate, err error) {
+	predicate = &Predicate{text: text}
+	for _, part := range strings.Split(text, Separator) {
+		if part == "" {
+			continue
+		}
+		index := strings.IndexAny(part, "=~")
+		if index <= 0 {
+			return nil, failed.Because("invalid condition "+part+", expected field=value or field~regexp", nil)
+		}
+		c := condition{field: part[:index], value: part[index+1:]}
+		if !knownField(c.field) {
+			return nil, failed.Because("unknown field "+c.field, nil)
+		}
+		if part[index] == '~' {
+			c.regexp, err = regexp.Compile(c.value)
+			if err != nil {
+				return nil, failed.Because("invalid regexp in condition "+part, err)
+			}
+		}
+		predicate.conditions = append(predicate.conditions, c)
+	}
+	if len(predicate.conditions) == 0 {
+		return nil, failed.Because("predicate "+text+" has no conditions", nil)
+	}
+	return predicate, nil
+}
+
+func knownField(field string) bool {
+	switch field {
+	case "exchange", "routing-key", "correlation-id", "reply-to", "body":
+		return true
+	}
+	return strings.HasPrefix(field, "header.") || strings.HasPrefix(field, "body.")
+}
+
+func (p *Predicate) String() string {
+	return p.text
+}
+
+// Match reports whether every condition of the predicate holds for the delivery.
+func (p *Predicate) Match(d amqp091.Delivery) bool {
+	var doc any
+	var decoded, isJSON bool
+	for _, c := range p.conditions {
+		var actual string
+		var found bool
+		switch {
+		case c.field == "exchange":
+			actual, found = d.Exchange, true
+		case c.field == "routing-key":
+			actual, found = d.RoutingKey, true
+		case c.field == "correlation-id":
+			actual, found = d.CorrelationId, true
+		case c.field == "reply-to":
+			actual, found = d.ReplyTo, true
+		case c.field == "body":
+			actual, found = string(d.Body), true
+		case strings.HasPrefix(c.field, "header."):
+			var value any
+			value, found = d.Headers[strings.TrimPrefix(c.field, "header.")]
+			actual = fmt.Sprint(value)
+		case strings.HasPrefix(c.field, "body."):
+			if !decoded {
+				var err error
+				doc, err = jsonpath.Decode(d.Body)
+				decoded, isJSON = true, err == nil
+			}
+			if isJSON {
+				var value any
+				value, found = jsonpath.Lookup(doc, strings.TrimPrefix(c.field, "body."))
+				actual = jsonpath.String(value)
+			}
+		}
+		if !found || !c.matches(actual) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c condition) matches(actual string) bool {
+	if c.regexp != nil {
+		return c.regexp.MatchString(actual)
+	}
+	return actual == c.value
+}
+
+// Describe renders a delivery in predicate syntax, so that it can be compared with predicates line by line.
+func Describe(d amqp091.Delivery, view render.BodyView) string {
+	return strings.Join([]string{
+		"exchange=" + d.Exchange,
+		"routing-key=" + d.RoutingKey,
+		"body=" + view.Line(d.Body),
+	}, Separator)
+}
@@ -0,0 +1,62 @@
+package predicate
+
+import (
+	"testing"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+func TestMatch(t *testing.T) {
+	delivery := amqp091.Delivery{
+		Exchange:      "orders",
+		RoutingKey:    "order.created",
+		CorrelationId: "c-1",
+		Headers:       amqp091.Table{"tenant": "acme", "attempt": int32(2)},
+		Body:          []byte(`{"order":{"id":42,"paid":false,"items":[{"sku":"A-1"}]}}`),
+	}
+	tests := []struct {
+		predicate string
+		match     bool
+	}{
+		{"routing-key=order.created", true},
+		{"routing-key=order.paid", false},
+		{"exchange=orders;routing-key~^order\\.", true},
+		{"correlation-id=c-1", true},
+		{"header.tenant=acme", true},
+		{"header.attempt=2", true},
+		{"header.missing=", false},
+		{"body.order.id=42", true},
+		{"body.order.paid=false", true},
+		{"body.order.items.0.sku=A-1", true},
+		{"body.order.items.1.sku=A-1", false},
+		{"body.order.id=42;header.tenant=other", false},
+		{"body~\"id\":42", true},
+	}
+	for _, test := range tests {
+		p, err := Parse(test.predicate)
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", test.predicate, err)
+		}
+		if got := p.Match(delivery); got != test.match {
+			t.Errorf("%s: expected match %v, got %v", test.predicate, test.match, got)
+		}
+	}
+}
+
+func TestMatchNonJSONBody(t *testing.T) {
+	p, err := Parse("body.order.id=42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Match(amqp091.Delivery{Body: []byte("not json")}) {
+		t.Error("expected body field condition not to match a non JSON body")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, text := range []string{"", ";", "routing-key", "=value", "unknown=value", "body~["} {
+		if _, err := Parse(text); err == nil {
+			t.Errorf("%q: expected an error", text)
+		}
+	}
+}
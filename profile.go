@@ -0,0 +1,94 @@
+package main
+
+import (
+	"log"
+	"path/filepath"
+
+	"github.com/fatih/color"
+	"github.com/ghokun/coyote/config"
+	"github.com/ghokun/coyote/contract"
+	"github.com/ghokun/coyote/redact"
+	"github.com/ghokun/coyote/render"
+	"github.com/urfave/cli/v3"
+)
+
+// configFilename returns the configuration file given with --config, or the default one.
+func configFilename(cli *cli.Command) string {
+	if cli.IsSet("config") {
+		return cli.String("config")
+	}
+	return config.DefaultFilename()
+}
+
+// profile returns the profile selected with --profile from the file given with --config. Without either flag,
+// the default profile of the default configuration file is used when it exists.
+func profile(cli *cli.Command) (config.Profile, error) {
+	conf, err := config.Load(configFilename(cli), cli.IsSet("config"))
+	if err != nil {
+		return config.Profile{}, err
+	}
+	return conf.Profile(cli.String("profile"), cli.IsSet("profile"))
+}
+
+// profileRedactor compiles the redaction rules of the selected profile.
+func profileRedactor(cli *cli.Command) (*redact.Redactor, error) {
+	p, err := profile(cli)
+	if err != nil {
+		return nil, err
+	}
+	r, err := redact.New(p.Redact, p.Salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.Redact) > 0 {
+		log.Printf("🙈 Redacting messages with %s rules of profile %s", color.YellowString("%d", len(p.Redact)), color.YellowString(cli.String("profile")))
+	}
+	return r, nil
+}
+
+// profileValidator compiles the contracts given with --contract, followed by those of the selected profile.
+func profileValidator(cli *cli.Command) (*contract.Validator, error) {
+	var contracts []contract.Contract
+	for _, text := range cli.StringSlice("contract") {
+		c, err := contract.Parse(text)
+		if err != nil {
+			return nil, err
+		}
+		contracts = append(contracts, c)
+	}
+	p, err := profile(cli)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range p.Contracts {
+		if c.Schema != "" && !filepath.IsAbs(c.Schema) {
+			c.Schema = filepath.Join(filepath.Dir(configFilename(cli)), c.Schema)
+		}
+		contracts = append(contracts, c)
+	}
+	v, err := contract.New(contracts)
+	if err != nil {
+		return nil, err
+	}
+	if v != nil {
+		log.Printf("📜 Validating messages against %s contracts", color.YellowString("%d", len(contracts)))
+	}
+	return v, nil
+}
+
+// profileFormat parses the template given with --format, either the name of a format of the selected profile or a
+// template itself. It returns nil when captured messages are printed in full.
+func profileFormat(cli *cli.Command) (*render.Template, error) {
+	if !cli.IsSet("format") {
+		return nil, nil
+	}
+	p, err := profile(cli)
+	if err != nil {
+		return nil, err
+	}
+	text := cli.String("format")
+	if named, found := p.Formats[text]; found {
+		text = named
+	}
+	return render.New(text)
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPublishInterval(t *testing.T) {
+	tests := []struct {
+		rate     float64
+		expected time.Duration
+	}{
+		{0, 0},
+		{10, 100 * time.Millisecond},
+		{0.5, 2 * time.Second},
+		{1e9, time.Nanosecond},
+	}
+	for _, test := range tests {
+		if interval, err := publishInterval(test.rate); err != nil || interval != test.expected {
+			t.Errorf("expected a rate of %g to publish every %s, got %s, %v", test.rate, test.expected, interval, err)
+		}
+	}
+	for _, rate := range []float64{-1, 1e10, math.Inf(1), math.NaN()} {
+		if _, err := publishInterval(rate); err == nil {
+			t.Errorf("expected a rate of %g to fail", rate)
+		}
+	}
+	if _, err := publishInterval(math.NaN()); err == nil || !strings.Contains(err.Error(), "rate must be a number") {
+		t.Errorf("expected NaN to be rejected as not a number, got %v", err)
+	}
+}
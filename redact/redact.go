@@ -0,0 +1,289 @@
+package redact
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	failed "github.com/ghokun/coyote/error"
+	"github.com/ghokun/coyote/jsonpath"
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// Actions applied to what a rule matches.
+const (
+	Mask = "mask"
+	Hash = "hash"
+	Drop = "drop"
+)
+
+// Masked replaces masked values.
+const Masked = "****"
+
+// patterns are the regular expressions rules may refer to by name.
+var patterns = map[string]*regexp.Regexp{
+	"email": regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	"card":  regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`),
+	"jwt":   regexp.MustCompile(`eyJ[A-Za-z0-9_-]*\.eyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]*`),
+}
+
+// Rule redacts a header by name, a JSON body field by path or what a regular expression matches in the body and
+// string headers. Paths are dot separated as in predicates, a * segment matches every field or array element.
+// Patterns name a built-in regular expression: email, card for payment card numbers or jwt.
+type Rule struct {
+	Name    string `json:"name"`
+	Header  string `json:"header,omitempty"`
+	Path    string `json:"path,omitempty"`
+	Regex   string `json:"regex,omitempty"`
+	Pattern string `json:"pattern,omitempty"`
+	Action  string `json:"action"`
+}
+
+type rule struct {
+	Rule
+	path   []string
+	regexp *regexp.Regexp
+}
+
+// Redactor applies the rules of a profile to deliveries before they are printed or stored.
+type Redactor struct {
+	rules []rule
+	salt  string
+}
+
+// New compiles the rules. Hashes are salted, so that they can be correlated within the captures of a profile
+// without being looked up in precomputed tables.
+func New(rules []Rule, salt string) (*Redactor, error) {
+	r := &Redactor{salt: salt}
+	for _, definition := range rules {
+		compiled := rule{Rule: definition}
+		targets := 0
+		if definition.Header != "" {
+			targets++
+		}
+		if definition.Path != "" {
+			compiled.path = strings.Split(definition.Path, ".")
+			targets++
+		}
+		if definition.Regex != "" {
+			var err error
+			if compiled.regexp, err = regexp.Compile(definition.Regex); err != nil {
+				return nil, failed.Because("invalid regex in redaction rule "+definition.name(), err)
+			}
+			targets++
+		}
+		if definition.Pattern != "" {
+			pattern, found := patterns[definition.Pattern]
+			if !found {
+				return nil, failed.Because("unknown pattern "+definition.Pattern+" in redaction rule "+definition.name()+", expected email, card or jwt", nil)
+			}
+			compiled.regexp = pattern
+			targets++
+		}
+		if targets != 1 {
+			return nil, failed.Because("redaction rule "+definition.name()+" must set exactly one of header, path, regex or pattern", nil)
+		}
+		switch definition.Action {
+		case Mask, Hash, Drop:
+		default:
+			return nil, failed.Because("unknown action "+definition.Action+" in redaction rule "+definition.name()+", expected mask, hash or drop", nil)
+		}
+		compiled.Name = definition.name()
+		r.rules = append(r.rules, compiled)
+	}
+	return r, nil
+}
+
+// name returns the name of the rule, or describes what it matches when it has none.
+func (r Rule) name() string {
+	switch {
+	case r.Name != "":
+		return r.Name
+	case r.Header != "":
+		return "header." + r.Header
+	case r.Path != "":
+		return "body." + r.Path
+	case r.Pattern != "":
+		return r.Pattern
+	default:
+		return r.Regex
+	}
+}
+
+// Redact redacts the headers and body of the delivery in place and returns the names of the rules that fired.
+// Headers are copied before being changed, as they may be shared with the caller.
+func (r *Redactor) Redact(d *amqp091.Delivery) (fired []string) {
+	if r == nil || len(r.rules) == 0 {
+		return nil
+	}
+	headersCopied := false
+	copyHeaders := func() {
+		if !headersCopied {
+			d.Headers = copyTable(d.Headers)
+			headersCopied = true
+		}
+	}
+	var doc any
+	decoded, docChanged := false, false
+	for _, rule := range r.rules {
+		hit := false
+		switch {
+		case rule.Header != "":
+			for name, value := range d.Headers {
+				if strings.EqualFold(name, rule.Header) {
+					copyHeaders()
+					if rule.Action == Drop {
+						delete(d.Headers, name)
+					} else {
+						d.Headers[name] = r.replace(rule.Action, jsonpath.String(value))
+					}
+					hit = true
+				}
+			}
+		case rule.path != nil:
+			if !decoded {
+				var err error
+				if doc, err = jsonpath.Decode(d.Body); err != nil {
+					doc = nil
+				}
+				decoded = true
+			}
+			if doc != nil {
+				var replaced bool
+				doc, replaced = r.redactPath(doc, rule.path, rule.Action)
+				hit = hit || replaced
+				docChanged = docChanged || replaced
+			}
+		default:
+			if docChanged {
+				d.Body, docChanged = encode(doc, d.Body), false
+				decoded = false
+			}
+			replace := func(match string) string {
+				if rule.Pattern == "card" && !luhn(match) {
+					return match
+				}
+				hit = true
+				if rule.Action == Drop {
+					return ""
+				}
+				return r.replace(rule.Action, match)
+			}
+			if body := rule.regexp.ReplaceAllStringFunc(string(d.Body), replace); hit {
+				d.Body = []byte(body)
+				decoded = false
+			}
+			for name, value := range d.Headers {
+				if text, ok := value.(string); ok {
+					if redacted := rule.regexp.ReplaceAllStringFunc(text, replace); redacted != text {
+						copyHeaders()
+						d.Headers[name] = redacted
+					}
+				}
+			}
+		}
+		if hit {
+			fired = append(fired, rule.Name)
+		}
+	}
+	if docChanged {
+		d.Body = encode(doc, d.Body)
+	}
+	return fired
+}
+
+func copyTable(headers amqp091.Table) amqp091.Table {
+	copied := make(amqp091.Table, len(headers))
+	for name, value := range headers {
+		copied[name] = value
+	}
+	return copied
+}
+
+// encode re-encodes a redacted document, leaving <, > and & as they are instead of escaping them for HTML.
+func encode(doc any, original []byte) []byte {
+	var encoded bytes.Buffer
+	encoder := json.NewEncoder(&encoded)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(doc); err != nil {
+		return original
+	}
+	return bytes.TrimSuffix(encoded.Bytes(), []byte("\n"))
+}
+
+func (r *Redactor) replace(action string, value string) string {
+	if action == Hash {
+		sum := sha256.Sum256([]byte(r.salt + value))
+		return "sha256:" + hex.EncodeToString(sum[:8])
+	}
+	return Masked
+}
+
+// redactPath applies the action to the values at the path, returning the document with them replaced or dropped.
+func (r *Redactor) redactPath(node any, path []string, action string) (any, bool) {
+	if len(path) == 0 {
+		return r.replace(action, jsonpath.String(node)), true
+	}
+	segment, rest := path[0], path[1:]
+	hit := false
+	switch n := node.(type) {
+	case map[string]any:
+		for name, child := range n {
+			if segment != "*" && segment != name {
+				continue
+			}
+			if len(rest) == 0 && action == Drop {
+				delete(n, name)
+				hit = true
+				continue
+			}
+			var replaced bool
+			n[name], replaced = r.redactPath(child, rest, action)
+			hit = hit || replaced
+		}
+	case []any:
+		kept := n[:0]
+		for i, child := range n {
+			if segment != "*" && segment != strconv.Itoa(i) {
+				kept = append(kept, child)
+				continue
+			}
+			if len(rest) == 0 && action == Drop {
+				hit = true
+				continue
+			}
+			var replaced bool
+			child, replaced = r.redactPath(child, rest, action)
+			hit = hit || replaced
+			kept = append(kept, child)
+		}
+		return slices.Clip(kept), hit
+	}
+	return node, hit
+}
+
+// luhn reports whether the digits of a card number candidate pass the Luhn checksum, so that other long numbers are kept.
+func luhn(candidate string) bool {
+	sum, double := 0, false
+	for i := len(candidate) - 1; i >= 0; i-- {
+		c := candidate[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		digit := int(c - '0')
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+	return sum%10 == 0
+}
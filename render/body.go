@@ -0,0 +1,123 @@
+package render
+
+import (
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	failed "github.com/ghokun/coyote/error"
+)
+
+// Body modes.
+const (
+	BodiesNone    = "none"
+	BodiesSummary = "summary"
+	BodiesFull    = "full"
+)
+
+// summaryLength is the number of bytes of a body previewed by summaries.
+const summaryLength = 64
+
+// BodyView prints bodies without corrupting terminals: binary bodies as a hexdump, long ones truncated.
+type BodyView struct {
+	// Mode is none to print the size of bodies only, summary to print it with a one-line preview or full.
+	Mode string
+	// MaxLength is the number of bytes full bodies are truncated to, 0 prints them whole.
+	MaxLength int
+}
+
+// NewBodyView validates the mode and maximum length of printed bodies.
+func NewBodyView(mode string, maxLength int) (BodyView, error) {
+	if !slices.Contains([]string{BodiesNone, BodiesSummary, BodiesFull}, mode) {
+		return BodyView{}, failed.Because("bodies must be one of none, summary or full: "+mode, nil)
+	}
+	if maxLength < 0 {
+		return BodyView{}, failed.Because("max body length must not be negative", nil)
+	}
+	return BodyView{Mode: mode, MaxLength: maxLength}, nil
+}
+
+// Binary reports whether a body is not printable text, i.e. not valid UTF-8 or holding control characters other than
+// whitespace, such as terminal escape sequences.
+func Binary(body []byte) bool {
+	if !utf8.Valid(body) {
+		return true
+	}
+	for _, r := range string(body) {
+		if unicode.IsControl(r) && r != '\n' && r != '\r' && r != '\t' {
+			return true
+		}
+	}
+	return false
+}
+
+// Render returns the body as printed in the mode of the view.
+func (v BodyView) Render(body []byte) string {
+	binary := Binary(body)
+	switch v.Mode {
+	case BodiesNone:
+		return size(len(body))
+	case BodiesSummary:
+		preview, rest := cut(body, summaryLength, binary)
+		if binary {
+			return fmt.Sprintf("%s, binary %x%s", size(len(body)), preview, ellipsis(rest))
+		}
+		return fmt.Sprintf("%s, %s%s", size(len(body)), strings.Join(strings.Fields(string(preview)), " "), ellipsis(rest))
+	default:
+		shown, rest := cut(body, v.MaxLength, binary)
+		text := string(shown)
+		if binary {
+			text = "binary\n" + strings.TrimSuffix(hex.Dump(shown), "\n")
+		}
+		if rest > 0 {
+			text += fmt.Sprintf("\n… %s more", size(rest))
+		}
+		return text
+	}
+}
+
+// Line returns the body as printed in the mode of the view on a single line, for reports listing a message per line.
+// Line breaks of text are replaced by spaces and binary bodies are printed in hex.
+func (v BodyView) Line(body []byte) string {
+	if v.Mode != BodiesFull {
+		return v.Render(body)
+	}
+	binary := Binary(body)
+	shown, rest := cut(body, v.MaxLength, binary)
+	text := strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ").Replace(string(shown))
+	if binary {
+		text = fmt.Sprintf("binary %x", shown)
+	}
+	if rest > 0 {
+		text += fmt.Sprintf("… %s more", size(rest))
+	}
+	return text
+}
+
+// cut returns the first bytes of a body, not splitting a character of text, and the number of bytes left out.
+func cut(body []byte, length int, binary bool) ([]byte, int) {
+	if length <= 0 || len(body) <= length {
+		return body, 0
+	}
+	for !binary && length > 0 && !utf8.RuneStart(body[length]) {
+		length--
+	}
+	return body[:length], len(body) - length
+}
+
+func ellipsis(rest int) string {
+	if rest > 0 {
+		return "…"
+	}
+	return ""
+}
+
+func size(bytes int) string {
+	if bytes == 1 {
+		return "1 byte"
+	}
+	return fmt.Sprintf("%d bytes", bytes)
+}
@@ -0,0 +1,82 @@
+package render
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBinary(t *testing.T) {
+	for body, expected := range map[string]bool{
+		"{\"id\":1}\n\tok\r\n": false,
+		"héllo":                false,
+		"\xff\xfe":             true,
+		"\x1b[2Jcleared":       true,
+		"a\x00b":               true,
+	} {
+		if Binary([]byte(body)) != expected {
+			t.Errorf("expected %q to be binary %t", body, expected)
+		}
+	}
+}
+
+func TestBodyView(t *testing.T) {
+	text := []byte("{\"order\":\n  {\"id\": 42}}")
+	binary := []byte{0x00, 0x01, 0xff, 0x1b}
+	tests := []struct {
+		mode      string
+		maxLength int
+		body      []byte
+		expected  string
+	}{
+		{BodiesNone, 0, text, "23 bytes"},
+		{BodiesNone, 0, []byte("a"), "1 byte"},
+		{BodiesSummary, 0, text, `23 bytes, {"order": {"id": 42}}`},
+		{BodiesSummary, 0, []byte(strings.Repeat("a", 70)), "70 bytes, " + strings.Repeat("a", 64) + "…"},
+		{BodiesSummary, 0, binary, "4 bytes, binary 0001ff1b"},
+		{BodiesFull, 0, text, string(text)},
+		{BodiesFull, 10, text, "{\"order\":\n\n… 13 bytes more"},
+		{BodiesFull, 2, []byte("aé"), "a\n… 2 bytes more"},
+		{BodiesFull, 0, binary, "binary\n00000000  00 01 ff 1b                                       |....|"},
+		{BodiesFull, 2, binary, "binary\n00000000  00 01                                             |..|\n… 2 bytes more"},
+	}
+	for _, test := range tests {
+		v, err := NewBodyView(test.mode, test.maxLength)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rendered := v.Render(test.body); rendered != test.expected {
+			t.Errorf("expected %q in %s mode limited to %d bytes to render\n%q\ngot\n%q", test.body, test.mode, test.maxLength, test.expected, rendered)
+		}
+	}
+	if _, err := NewBodyView("some", 0); err == nil {
+		t.Error("expected an unknown mode to fail")
+	}
+	if _, err := NewBodyView(BodiesFull, -1); err == nil {
+		t.Error("expected a negative length to fail")
+	}
+}
+
+func TestBodyLine(t *testing.T) {
+	tests := []struct {
+		mode      string
+		maxLength int
+		body      string
+		expected  string
+	}{
+		{BodiesFull, 0, "{\"id\":1,\r\n\"sku\":\"a\"}", `{"id":1, "sku":"a"}`},
+		{BodiesFull, 4, "abc\ndef", "abc … 3 bytes more"},
+		{BodiesFull, 0, "\x1b[2J", "binary 1b5b324a"},
+		{BodiesFull, 2, "\x1b[2J", "binary 1b5b… 2 bytes more"},
+		{BodiesSummary, 0, "a\nb", "3 bytes, a b"},
+		{BodiesNone, 0, "a\nb", "3 bytes"},
+	}
+	for _, test := range tests {
+		v, err := NewBodyView(test.mode, test.maxLength)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if line := v.Line([]byte(test.body)); line != test.expected {
+			t.Errorf("expected %q in %s mode limited to %d bytes to be printed as %q, got %q", test.body, test.mode, test.maxLength, test.expected, line)
+		}
+	}
+}
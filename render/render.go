@@ -0,0 +1,220 @@
+package render
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+	failed "github.com/ghokun/coyote/error"
+	"github.com/ghokun/coyote/jsonpath"
+	"github.com/ghokun/coyote/store"
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// Message is what templates are executed against, e.g. {{.RoutingKey}} {{jq .Body ".order.id"}}.
+type Message struct {
+	Source        string
+	Vhost         string
+	Exchange      string
+	RoutingKey    string
+	MessageId     string
+	CorrelationId string
+	ReplyTo       string
+	ContentType   string
+	AppId         string
+	UserId        string
+	Type          string
+	// Timestamp is the timestamp property set by the publisher, Received the time the message was captured at.
+	Timestamp  time.Time
+	Received   time.Time
+	Headers    amqp091.Table
+	Body       Body
+	Validation *store.Validation
+}
+
+// Body is printed through the body view, e.g. as a hexdump when binary, while jq and base64 read its raw bytes.
+type Body struct {
+	raw  []byte
+	view BodyView
+}
+
+// String returns the body as printed by the view.
+func (b Body) String() string {
+	return b.view.Render(b.raw)
+}
+
+// NewMessage returns the template data of a delivery captured from the origin, printing its body through the view.
+func NewMessage(origin store.Origin, d amqp091.Delivery, received time.Time, validation *store.Validation, view BodyView) Message {
+	return Message{
+		Source:        origin.Source,
+		Vhost:         origin.Vhost,
+		Exchange:      d.Exchange,
+		RoutingKey:    d.RoutingKey,
+		MessageId:     d.MessageId,
+		CorrelationId: d.CorrelationId,
+		ReplyTo:       d.ReplyTo,
+		ContentType:   d.ContentType,
+		AppId:         d.AppId,
+		UserId:        d.UserId,
+		Type:          d.Type,
+		Timestamp:     d.Timestamp,
+		Received:      received,
+		Headers:       d.Headers,
+		Body:          Body{raw: d.Body, view: view},
+		Validation:    validation,
+	}
+}
+
+// Header returns the header of the given name, matched case-insensitively, or an empty string.
+func (m Message) Header(name string) string {
+	for key, value := range m.Headers {
+		if strings.EqualFold(key, name) {
+			return jsonpath.String(value)
+		}
+	}
+	return ""
+}
+
+// Template renders messages with a text/template and its helper functions.
+type Template struct {
+	template *template.Template
+}
+
+var colors = map[string]color.Attribute{
+	"red":     color.FgRed,
+	"green":   color.FgGreen,
+	"yellow":  color.FgYellow,
+	"blue":    color.FgBlue,
+	"magenta": color.FgMagenta,
+	"cyan":    color.FgCyan,
+	"white":   color.FgWhite,
+	"bold":    color.Bold,
+	"faint":   color.Faint,
+}
+
+var layouts = map[string]string{
+	"rfc3339":     time.RFC3339,
+	"rfc3339nano": time.RFC3339Nano,
+	"datetime":    time.DateTime,
+	"date":        time.DateOnly,
+	"time":        time.TimeOnly,
+	"kitchen":     time.Kitchen,
+	"stamp":       time.StampMilli,
+}
+
+var functions = template.FuncMap{
+	"jq":       jq,
+	"jsonpath": jq,
+	"truncate": truncate,
+	"color":    colorize,
+	"base64":   encode,
+	"time":     format,
+	"json":     encodeJSON,
+}
+
+// New parses a template. Besides the built-in functions of text/template, templates may call
+//
+//	jq BODY PATH      the JSON value at a path such as .order.items[0].sku, empty when absent; jsonpath is an alias
+//	truncate N TEXT   the first N characters of a text, followed by … when longer
+//	color NAME TEXT   a text in red, green, yellow, blue, magenta, cyan, white, bold or faint
+//	base64 TEXT       a text encoded as standard base64, the raw bytes of .Body
+//	time LAYOUT TIME  a time formatted with rfc3339, rfc3339nano, datetime, date, time, kitchen, stamp or a Go layout
+//	json VALUE        a value encoded as JSON, strings as they are
+//
+// and look headers up with {{.Header "name"}}.
+func New(text string) (*Template, error) {
+	t, err := template.New("format").Funcs(functions).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, failed.Because("failed to parse format", err)
+	}
+	return &Template{template: t}, nil
+}
+
+// Render writes a message as rendered by the template, followed by a newline unless it ends with one.
+func (t *Template) Render(w io.Writer, m Message) error {
+	var rendered bytes.Buffer
+	if err := t.template.Execute(&rendered, m); err != nil {
+		return failed.Because("failed to render message", err)
+	}
+	if !bytes.HasSuffix(rendered.Bytes(), []byte("\n")) {
+		rendered.WriteByte('\n')
+	}
+	_, err := w.Write(rendered.Bytes())
+	return err
+}
+
+func jq(body any, path string) (string, error) {
+	var content []byte
+	switch b := body.(type) {
+	case Body:
+		content = b.raw
+	case string:
+		content = []byte(b)
+	case []byte:
+		content = b
+	default:
+		return "", failed.Because(fmt.Sprintf("jq expects a body, got %T", body), nil)
+	}
+	doc, err := jsonpath.Decode(content)
+	if err != nil {
+		return "", nil
+	}
+	// jq style paths are translated into the dot separated paths of the jsonpath package.
+	path = strings.NewReplacer("[", ".", "]", "").Replace(path)
+	value, found := jsonpath.Lookup(doc, strings.Trim(path, "."))
+	if !found {
+		return "", nil
+	}
+	return jsonpath.String(value), nil
+}
+
+// text returns the printed form of a template value, so that .Body is passed to functions through the body view.
+func text(value any) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	return fmt.Sprint(value)
+}
+
+func truncate(length int, value any) string {
+	text := text(value)
+	if length < 0 || utf8.RuneCountInString(text) <= length {
+		return text
+	}
+	return string([]rune(text)[:length]) + "…"
+}
+
+func colorize(name string, value any) (string, error) {
+	attribute, found := colors[strings.ToLower(name)]
+	if !found {
+		return "", failed.Because("unknown color "+name, nil)
+	}
+	return color.New(attribute).Sprint(text(value)), nil
+}
+
+func encode(value any) string {
+	if b, ok := value.(Body); ok {
+		return base64.StdEncoding.EncodeToString(b.raw)
+	}
+	return base64.StdEncoding.EncodeToString([]byte(text(value)))
+}
+
+func encodeJSON(value any) string {
+	if b, ok := value.(Body); ok {
+		return b.String()
+	}
+	return jsonpath.String(value)
+}
+
+func format(layout string, t time.Time) string {
+	if named, found := layouts[strings.ToLower(layout)]; found {
+		layout = named
+	}
+	return t.Format(layout)
+}
@@ -0,0 +1,74 @@
+package render
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ghokun/coyote/store"
+	"github.com/rabbitmq/amqp091-go"
+)
+
+func TestRender(t *testing.T) {
+	received := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	m := NewMessage(store.Origin{Source: "prod", Vhost: "/"}, amqp091.Delivery{
+		Exchange:   "orders",
+		RoutingKey: "order.created",
+		Headers:    amqp091.Table{"Tenant": "acme", "retries": int32(2)},
+		Body:       []byte(`{"order":{"id":42,"items":[{"sku":"a"}]},"note":"a very long note"}`),
+	}, received, &store.Validation{Contract: "order.json", Valid: true}, BodyView{Mode: BodiesFull})
+
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{`{{.RoutingKey}} {{jq .Body ".order.id"}}`, "order.created 42\n"},
+		{`{{jsonpath .Body "order.items.0.sku"}} {{jq .Body ".order.items[0].sku"}} [{{jq .Body ".missing"}}]`, "a a []\n"},
+		{`{{jq .Body ".order.items"}}`, `[{"sku":"a"}]` + "\n"},
+		{`{{.Header "tenant"}} {{.Header "retries"}} [{{.Header "absent"}}]`, "acme 2 []\n"},
+		{`{{jq .Body ".note" | truncate 6}}`, "a very…\n"},
+		{`{{.Exchange | base64}}`, "b3JkZXJz\n"},
+		{`{{.Body | truncate 9}} {{json .Body | truncate 9}}`, `{"order":… {"order":…` + "\n"},
+		{`{{time "datetime" .Received}} {{.Received | time "15:04"}}`, "2024-05-01 12:30:00 12:30\n"},
+		{`{{.Source}}{{.Vhost}} {{.Validation.Valid}}` + "\n", "prod/ true\n"},
+		{`{{.RoutingKey | color "yellow"}}`, "order.created\n"},
+	}
+	for _, test := range tests {
+		tmpl, err := New(test.format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var rendered strings.Builder
+		if err := tmpl.Render(&rendered, m); err != nil {
+			t.Fatalf("expected %s to render, got %v", test.format, err)
+		}
+		if rendered.String() != test.expected {
+			t.Errorf("expected %s to render %q, got %q", test.format, test.expected, rendered.String())
+		}
+	}
+
+	tmpl, err := New(`{{.Body}} | {{.Body | base64}} | {{.Body | color "bold"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary := NewMessage(store.Origin{}, amqp091.Delivery{Body: []byte("\x1b[2Jabcd")}, received, nil, BodyView{Mode: BodiesFull, MaxLength: 6})
+	var rendered strings.Builder
+	if err := tmpl.Render(&rendered, binary); err != nil {
+		t.Fatal(err)
+	}
+	expected := "binary\n00000000  1b 5b 32 4a 61 62                                 |.[2Jab|\n… 2 bytes more | G1sySmFiY2Q= | binary\n00000000  1b 5b 32 4a 61 62                                 |.[2Jab|\n… 2 bytes more\n"
+	if rendered.String() != expected {
+		t.Errorf("expected a binary body to be printed as a truncated hexdump and encoded whole, got %q", rendered.String())
+	}
+
+	if _, err := New(`{{.RoutingKey`); err == nil {
+		t.Error("expected an unterminated action to fail")
+	}
+	tmpl, err = New(`{{.RoutingKey | color "purple"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tmpl.Render(&strings.Builder{}, m); err == nil {
+		t.Error("expected an unknown color to fail")
+	}
+}
@@ -0,0 +1,214 @@
+package routing
+
+import (
+	"fmt"
+	"strings"
+
+	failed "github.com/ghokun/coyote/error"
+	"github.com/ghokun/coyote/management"
+)
+
+// Hop is a binding a message travels along, from its source exchange to its destination.
+type Hop struct {
+	Source          string
+	Destination     string
+	DestinationType string
+	RoutingKey      string
+}
+
+// Route is a destination a message reaches, together with the bindings it travels along.
+type Route struct {
+	Destination string
+	Path        []Hop
+}
+
+// Routes are the queues and exchanges that would receive a message.
+type Routes struct {
+	Queues    []Route
+	Exchanges []Route
+}
+
+// builtinTypes are the exchanges every vhost has, which definition exports leave out.
+var builtinTypes = map[string]string{
+	"":            "direct",
+	"amq.direct":  "direct",
+	"amq.fanout":  "fanout",
+	"amq.topic":   "topic",
+	"amq.headers": "headers",
+	"amq.match":   "headers",
+}
+
+// Simulate computes, without publishing, where a message published to the exchange would be routed to.
+// Exchange-to-exchange bindings are followed with the same routing key and headers, and an exchange that
+// routes the message nowhere hands it to its alternate exchange. A queue reached on several paths is
+// reported once, on the first path found.
+func Simulate(definitions *management.Definitions, exchange string, routingKey string, headers map[string]any) (routes *Routes, err error) {
+	exchanges := map[string]management.Exchange{}
+	for name, kind := range builtinTypes {
+		exchanges[name] = management.Exchange{Name: name, Type: kind}
+	}
+	for _, e := range definitions.Exchanges {
+		exchanges[e.Name] = e
+	}
+	if _, found := exchanges[exchange]; !found {
+		return nil, failed.Because("exchange "+exchange+" is not defined", nil)
+	}
+
+	routes = &Routes{}
+	visited := map[string]bool{}
+	delivered := map[string]bool{}
+	var walk func(name string, path []Hop) error
+	walk = func(name string, path []Hop) error {
+		if visited[name] {
+			return nil
+		}
+		visited[name] = true
+		e, found := exchanges[name]
+		if !found {
+			return nil
+		}
+		var hops []Hop
+		if name == "" {
+			// Every queue is implicitly bound to the default exchange with its own name.
+			for _, q := range definitions.Queues {
+				if q.Name == routingKey {
+					hops = append(hops, Hop{Source: name, Destination: q.Name, DestinationType: "queue", RoutingKey: q.Name})
+				}
+			}
+		} else {
+			for _, b := range definitions.Bindings {
+				if b.Source != name {
+					continue
+				}
+				matched, err := Match(e.Type, b.RoutingKey, b.Arguments, routingKey, headers)
+				if err != nil {
+					return failed.Because("failed to route through exchange "+name, err)
+				}
+				if matched {
+					hops = append(hops, Hop{Source: name, Destination: b.Destination, DestinationType: b.DestinationType, RoutingKey: b.RoutingKey})
+				}
+			}
+		}
+		if len(hops) == 0 {
+			if alternate, ok := e.Arguments["alternate-exchange"].(string); ok {
+				hops = append(hops, Hop{Source: name, Destination: alternate, DestinationType: "exchange", RoutingKey: "alternate-exchange"})
+			}
+		}
+		for _, hop := range hops {
+			hopPath := append(append([]Hop{}, path...), hop)
+			if hop.DestinationType == "exchange" {
+				if !visited[hop.Destination] {
+					routes.Exchanges = append(routes.Exchanges, Route{Destination: hop.Destination, Path: hopPath})
+				}
+				if err := walk(hop.Destination, hopPath); err != nil {
+					return err
+				}
+			} else if !delivered[hop.Destination] {
+				delivered[hop.Destination] = true
+				routes.Queues = append(routes.Queues, Route{Destination: hop.Destination, Path: hopPath})
+			}
+		}
+		return nil
+	}
+	return routes, walk(exchange, nil)
+}
+
+// Match reports whether a binding of an exchange of the given type matches a message.
+func Match(exchangeType string, bindingKey string, arguments map[string]any, routingKey string, headers map[string]any) (bool, error) {
+	switch exchangeType {
+	case "direct":
+		return bindingKey == routingKey, nil
+	case "fanout":
+		return true, nil
+	case "topic":
+		return TopicMatch(bindingKey, routingKey), nil
+	case "headers":
+		return HeadersMatch(arguments, headers)
+	default:
+		return false, failed.Because("unsupported exchange type "+exchangeType, nil)
+	}
+}
+
+// TopicMatch reports whether a routing key matches a topic binding pattern. Both are dot separated words,
+// in the pattern * matches exactly one word and # matches zero or more words.
+func TopicMatch(pattern string, routingKey string) bool {
+	return topicMatch(topicWords(pattern), topicWords(routingKey))
+}
+
+// topicWords splits a routing key or pattern into its words. As in RabbitMQ, an empty one has no words at all,
+// so that only # matches an empty routing key.
+func topicWords(key string) []string {
+	if key == "" {
+		return nil
+	}
+	return strings.Split(key, ".")
+}
+
+func topicMatch(pattern []string, words []string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case "#":
+			// Collapse consecutive wildcards, then try every number of words for #.
+			rest := pattern[1:]
+			for len(rest) > 0 && rest[0] == "#" {
+				rest = rest[1:]
+			}
+			if len(rest) == 0 {
+				return true
+			}
+			for i := 0; i <= len(words); i++ {
+				if topicMatch(rest, words[i:]) {
+					return true
+				}
+			}
+			return false
+		case "*":
+			if len(words) == 0 {
+				return false
+			}
+		default:
+			if len(words) == 0 || pattern[0] != words[0] {
+				return false
+			}
+		}
+		pattern, words = pattern[1:], words[1:]
+	}
+	return len(words) == 0
+}
+
+// HeadersMatch reports whether message headers match the arguments of a headers exchange binding.
+// x-match selects whether all (default) or any of the arguments must match. Arguments starting with x- are
+// only compared with all-with-x and any-with-x. An argument without a value matches when the header is present.
+// Values are compared by their text, since headers given on the command line are strings.
+func HeadersMatch(arguments map[string]any, headers map[string]any) (bool, error) {
+	mode := "all"
+	if value, found := arguments["x-match"]; found {
+		mode = fmt.Sprint(value)
+	}
+	var any, withX bool
+	switch mode {
+	case "all":
+	case "any":
+		any = true
+	case "all-with-x":
+		withX = true
+	case "any-with-x":
+		any, withX = true, true
+	default:
+		return false, failed.Because("unsupported x-match "+mode, nil)
+	}
+	for key, expected := range arguments {
+		if key == "x-match" || (!withX && strings.HasPrefix(key, "x-")) {
+			continue
+		}
+		actual, present := headers[key]
+		matched := present && (expected == nil || fmt.Sprint(expected) == fmt.Sprint(actual))
+		if any && matched {
+			return true, nil
+		}
+		if !any && !matched {
+			return false, nil
+		}
+	}
+	return !any, nil
+}
@@ -0,0 +1,192 @@
+package routing
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/ghokun/coyote/management"
+)
+
+func TestTopicMatch(t *testing.T) {
+	tests := []struct {
+		pattern    string
+		routingKey string
+		match      bool
+	}{
+		{"order.created", "order.created", true},
+		{"order.created", "order.paid", false},
+		{"order.*", "order.created", true},
+		{"order.*", "order", false},
+		{"order.*", "order.created.eu", false},
+		{"*.created", "order.created", true},
+		{"*.*", "order.created", true},
+		{"*", "", false},
+		{"#.#", "", true},
+		{"order.#", "", false},
+		{"#", "", true},
+		{"#", "order.created.eu", true},
+		{"order.#", "order", true},
+		{"order.#", "order.created.eu", true},
+		{"order.#", "invoice.created", false},
+		{"#.eu", "order.created.eu", true},
+		{"#.eu", "eu", true},
+		{"#.eu", "order.created.us", false},
+		{"order.#.eu", "order.eu", true},
+		{"order.#.eu", "order.created.paid.eu", true},
+		{"order.#.#.eu", "order.created.eu", true},
+		{"#.*.eu", "eu", false},
+		{"#.*.eu", "order.eu", true},
+		{"*.#.*", "order", false},
+		{"*.#.*", "order.created", true},
+		{"order..eu", "order..eu", true},
+		{"order.*.eu", "order..eu", true},
+		{"", "", true},
+		{"", "order", false},
+	}
+	for _, test := range tests {
+		if got := TopicMatch(test.pattern, test.routingKey); got != test.match {
+			t.Errorf("%q with %q: expected %v, got %v", test.pattern, test.routingKey, test.match, got)
+		}
+	}
+}
+
+func TestHeadersMatch(t *testing.T) {
+	headers := map[string]any{"tenant": "acme", "region": "eu", "attempt": "2", "x-source": "billing"}
+	tests := []struct {
+		name      string
+		arguments map[string]any
+		match     bool
+	}{
+		{"all by default", map[string]any{"tenant": "acme", "region": "eu"}, true},
+		{"all with a mismatch", map[string]any{"x-match": "all", "tenant": "acme", "region": "us"}, false},
+		{"all with a missing header", map[string]any{"tenant": "acme", "priority": "high"}, false},
+		{"all without arguments", map[string]any{}, true},
+		{"any with one match", map[string]any{"x-match": "any", "tenant": "other", "region": "eu"}, true},
+		{"any without a match", map[string]any{"x-match": "any", "tenant": "other", "region": "us"}, false},
+		{"any without arguments", map[string]any{"x-match": "any"}, false},
+		{"number compared as text", map[string]any{"attempt": float64(2)}, true},
+		{"presence only", map[string]any{"tenant": nil}, true},
+		{"presence of a missing header", map[string]any{"priority": nil}, false},
+		{"x- arguments are ignored", map[string]any{"tenant": "acme", "x-source": "shipping"}, true},
+		{"all-with-x compares x- arguments", map[string]any{"x-match": "all-with-x", "tenant": "acme", "x-source": "shipping"}, false},
+		{"any-with-x compares x- arguments", map[string]any{"x-match": "any-with-x", "tenant": "other", "x-source": "billing"}, true},
+		{"any ignores x- arguments", map[string]any{"x-match": "any", "x-source": "billing"}, false},
+	}
+	for _, test := range tests {
+		got, err := HeadersMatch(test.arguments, headers)
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", test.name, err)
+		}
+		if got != test.match {
+			t.Errorf("%s: expected %v, got %v", test.name, test.match, got)
+		}
+	}
+	if _, err := HeadersMatch(map[string]any{"x-match": "some"}, headers); err == nil {
+		t.Error("expected an error for an unsupported x-match")
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		exchangeType string
+		bindingKey   string
+		arguments    map[string]any
+		routingKey   string
+		match        bool
+	}{
+		{"direct", "order.created", nil, "order.created", true},
+		{"direct", "order.*", nil, "order.created", false},
+		{"fanout", "ignored", nil, "order.created", true},
+		{"topic", "order.*", nil, "order.created", true},
+		{"headers", "", map[string]any{"tenant": "acme"}, "ignored", true},
+		{"headers", "", map[string]any{"tenant": "other"}, "ignored", false},
+	}
+	for _, test := range tests {
+		got, err := Match(test.exchangeType, test.bindingKey, test.arguments, test.routingKey, map[string]any{"tenant": "acme"})
+		if err != nil {
+			t.Fatalf("%s %q: unexpected error %v", test.exchangeType, test.bindingKey, err)
+		}
+		if got != test.match {
+			t.Errorf("%s %q with %q: expected %v, got %v", test.exchangeType, test.bindingKey, test.routingKey, test.match, got)
+		}
+	}
+	if _, err := Match("x-consistent-hash", "1", nil, "order.created", nil); err == nil {
+		t.Error("expected an error for an unsupported exchange type")
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	definitions := &management.Definitions{
+		Exchanges: []management.Exchange{
+			{Name: "orders", Type: "topic", Arguments: map[string]any{"alternate-exchange": "unrouted"}},
+			{Name: "audit", Type: "fanout"},
+			{Name: "tenants", Type: "headers"},
+			{Name: "unrouted", Type: "fanout"},
+			{Name: "loop", Type: "fanout"},
+		},
+		Queues: []management.Queue{{Name: "billing"}, {Name: "shipping"}, {Name: "audit-log"}, {Name: "acme"}, {Name: "dead"}},
+		Bindings: []management.Binding{
+			{Source: "orders", Destination: "billing", DestinationType: "queue", RoutingKey: "order.created"},
+			{Source: "orders", Destination: "shipping", DestinationType: "queue", RoutingKey: "order.paid"},
+			{Source: "orders", Destination: "billing", DestinationType: "queue", RoutingKey: "order.*"},
+			{Source: "orders", Destination: "audit", DestinationType: "exchange", RoutingKey: "order.#"},
+			{Source: "orders", Destination: "tenants", DestinationType: "exchange", RoutingKey: "#"},
+			{Source: "audit", Destination: "audit-log", DestinationType: "queue"},
+			{Source: "audit", Destination: "loop", DestinationType: "exchange"},
+			{Source: "loop", Destination: "audit", DestinationType: "exchange"},
+			{Source: "tenants", Destination: "acme", DestinationType: "queue", Arguments: map[string]any{"tenant": "acme"}},
+			{Source: "unrouted", Destination: "dead", DestinationType: "queue"},
+		},
+	}
+	destinations := func(routes []Route) (names []string) {
+		for _, route := range routes {
+			names = append(names, route.Destination)
+		}
+		return names
+	}
+	tests := []struct {
+		name       string
+		exchange   string
+		routingKey string
+		headers    map[string]any
+		queues     []string
+		exchanges  []string
+	}{
+		{"topic and exchange bindings", "orders", "order.created", nil, []string{"billing", "audit-log"}, []string{"audit", "loop", "tenants"}},
+		{"headers through an exchange binding", "orders", "order.paid", map[string]any{"tenant": "acme"}, []string{"shipping", "billing", "audit-log", "acme"}, []string{"audit", "loop", "tenants"}},
+		{"catch all binding", "orders", "invoice.created", nil, nil, []string{"tenants"}},
+		{"fanout", "audit", "anything", nil, []string{"audit-log"}, []string{"loop"}},
+		{"unmatched headers", "tenants", "anything", map[string]any{"tenant": "other"}, nil, nil},
+		{"default exchange", "", "shipping", nil, []string{"shipping"}, nil},
+		{"default exchange without queue", "", "missing", nil, nil, nil},
+		{"builtin exchange without bindings", "amq.topic", "order.created", nil, nil, nil},
+	}
+	for _, test := range tests {
+		routes, err := Simulate(definitions, test.exchange, test.routingKey, test.headers)
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", test.name, err)
+		}
+		if got := destinations(routes.Queues); !slices.Equal(got, test.queues) {
+			t.Errorf("%s: expected queues %v, got %v", test.name, test.queues, got)
+		}
+		if got := destinations(routes.Exchanges); !slices.Equal(got, test.exchanges) {
+			t.Errorf("%s: expected exchanges %v, got %v", test.name, test.exchanges, got)
+		}
+	}
+
+	routes, err := Simulate(&management.Definitions{
+		Exchanges: []management.Exchange{{Name: "orders", Type: "direct", Arguments: map[string]any{"alternate-exchange": "unrouted"}}, {Name: "unrouted", Type: "fanout"}},
+		Queues:    []management.Queue{{Name: "dead"}},
+		Bindings:  []management.Binding{{Source: "unrouted", Destination: "dead", DestinationType: "queue"}},
+	}, "orders", "order.created", nil)
+	if err != nil {
+		t.Fatalf("alternate exchange: unexpected error %v", err)
+	}
+	if len(routes.Queues) != 1 || len(routes.Queues[0].Path) != 2 || routes.Queues[0].Path[0].Destination != "unrouted" {
+		t.Errorf("alternate exchange: expected dead via unrouted, got %+v", routes.Queues)
+	}
+
+	if _, err := Simulate(definitions, "missing", "order.created", nil); err == nil {
+		t.Error("expected an error for an undefined exchange")
+	}
+}
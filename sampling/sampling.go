@@ -0,0 +1,139 @@
+package sampling
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+
+	failed "github.com/ghokun/coyote/error"
+	"github.com/ghokun/coyote/routing"
+)
+
+// KeyRate samples messages whose routing key matches a topic pattern at a rate of their own.
+type KeyRate struct {
+	Pattern string
+	Rate    float64
+}
+
+// ParseKeyRate parses a per routing key rate written as pattern=rate, e.g. order.#=0.1.
+func ParseKeyRate(text string) (KeyRate, error) {
+	pattern, value, found := strings.Cut(text, "=")
+	if !found || pattern == "" {
+		return KeyRate{}, failed.Because("routing key rate must be written as pattern=rate: "+text, nil)
+	}
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil || !(rate >= 0 && rate <= 1) {
+		return KeyRate{}, failed.Because("routing key rate must be between 0 and 1: "+text, nil)
+	}
+	return KeyRate{Pattern: pattern, Rate: rate}, nil
+}
+
+// Options select which captured messages are written to sinks.
+type Options struct {
+	// Rate is the probability of keeping a message, 0 keeps every message.
+	Rate float64
+	// Every keeps one message in every given number of messages, 0 keeps every message.
+	Every int
+	// Keys override Rate for the routing keys they match, the first matching pattern wins.
+	Keys []KeyRate
+	// PerSecond caps the messages kept per second with a token bucket, 0 does not cap them.
+	PerSecond float64
+}
+
+// Sampler decides which messages are written to sinks. Sampling comes first, the token bucket only spends tokens
+// on the messages sampling kept.
+type Sampler struct {
+	options Options
+	seen    int
+	tokens  float64
+	refill  time.Time
+	random  func() float64
+}
+
+// New returns a sampler for the options, or nil when they keep every message.
+func New(options Options) (*Sampler, error) {
+	// Ranges are checked so that NaN, for which every comparison is false, falls outside them.
+	if !(options.Rate >= 0 && options.Rate <= 1) {
+		return nil, failed.Because("sample rate must be between 0 and 1", nil)
+	}
+	if options.Every < 0 {
+		return nil, failed.Because("1-in-N sampling must keep one message in a positive number of messages", nil)
+	}
+	if !(options.PerSecond >= 0) || math.IsInf(options.PerSecond, 1) {
+		return nil, failed.Because("rate limit must be a positive number of messages per second", nil)
+	}
+	if options.Rate == 0 && options.Every <= 1 && len(options.Keys) == 0 && options.PerSecond == 0 {
+		return nil, nil
+	}
+	return &Sampler{options: options, tokens: burst(options.PerSecond), random: rand.Float64}, nil
+}
+
+// burst is the capacity of the token bucket, which allows a second worth of messages at once.
+func burst(perSecond float64) float64 {
+	return max(perSecond, 1)
+}
+
+// Keep reports whether the message should be written to sinks. A nil sampler keeps every message.
+func (s *Sampler) Keep(routingKey string, now time.Time) bool {
+	if s == nil {
+		return true
+	}
+	s.seen++
+	if s.options.Every > 1 && (s.seen-1)%s.options.Every != 0 {
+		return false
+	}
+	if rate := s.rate(routingKey); rate < 1 && s.random() >= rate {
+		return false
+	}
+	if s.options.PerSecond > 0 {
+		if !s.refill.IsZero() {
+			s.tokens = min(s.tokens+now.Sub(s.refill).Seconds()*s.options.PerSecond, burst(s.options.PerSecond))
+		}
+		s.refill = now
+		if s.tokens < 1 {
+			return false
+		}
+		s.tokens--
+	}
+	return true
+}
+
+func (s *Sampler) rate(routingKey string) float64 {
+	for _, key := range s.options.Keys {
+		if routing.TopicMatch(key.Pattern, routingKey) {
+			return key.Rate
+		}
+	}
+	if s.options.Rate > 0 {
+		return s.options.Rate
+	}
+	return 1
+}
+
+// String describes the sampling rate, e.g. "1 in 10, 25%, order.#=10%, 100/s". A nil sampler describes no sampling.
+func (s *Sampler) String() string {
+	if s == nil {
+		return ""
+	}
+	var parts []string
+	if s.options.Every > 1 {
+		parts = append(parts, fmt.Sprintf("1 in %d", s.options.Every))
+	}
+	if s.options.Rate > 0 {
+		parts = append(parts, percent(s.options.Rate))
+	}
+	for _, key := range s.options.Keys {
+		parts = append(parts, key.Pattern+"="+percent(key.Rate))
+	}
+	if s.options.PerSecond > 0 {
+		parts = append(parts, strconv.FormatFloat(s.options.PerSecond, 'f', -1, 64)+"/s")
+	}
+	return strings.Join(parts, ", ")
+}
+
+func percent(rate float64) string {
+	return strconv.FormatFloat(rate*100, 'f', -1, 64) + "%"
+}
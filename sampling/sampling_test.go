@@ -0,0 +1,117 @@
+package sampling
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	if s, err := New(Options{Every: 1}); s != nil || err != nil {
+		t.Errorf("expected no sampler when every message is kept, got %v, %v", s, err)
+	}
+	if !(*Sampler)(nil).Keep("a", time.Now()) {
+		t.Error("expected a nil sampler to keep every message")
+	}
+	for _, options := range []Options{{Rate: 1.5}, {Rate: -1}, {Rate: math.NaN()}, {Every: -2}, {PerSecond: -1},
+		{PerSecond: math.NaN()}, {PerSecond: math.Inf(1)}} {
+		if _, err := New(options); err == nil {
+			t.Errorf("expected %+v to fail", options)
+		}
+	}
+}
+
+func TestParseKeyRate(t *testing.T) {
+	if key, err := ParseKeyRate("order.#=0.25"); err != nil || key != (KeyRate{Pattern: "order.#", Rate: 0.25}) {
+		t.Errorf("expected order.# at 0.25, got %+v, %v", key, err)
+	}
+	for _, text := range []string{"order.#", "=0.5", "order.#=2", "order.#=x", "order.#=NaN"} {
+		if _, err := ParseKeyRate(text); err == nil {
+			t.Errorf("expected %q to fail", text)
+		}
+	}
+}
+
+func TestKeep(t *testing.T) {
+	now := time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		options  Options
+		keys     []string
+		expected []bool
+	}{
+		{
+			name:     "1 in N",
+			options:  Options{Every: 3},
+			keys:     []string{"a", "a", "a", "a", "a", "a", "a"},
+			expected: []bool{true, false, false, true, false, false, true},
+		},
+		{
+			name:     "rate",
+			options:  Options{Rate: 0.5},
+			keys:     []string{"a", "a", "a", "a"},
+			expected: []bool{true, false, true, false},
+		},
+		{
+			name:     "routing key rate",
+			options:  Options{Rate: 0.5, Keys: []KeyRate{{Pattern: "audit.#", Rate: 0}, {Pattern: "order.*", Rate: 1}}},
+			keys:     []string{"order.paid", "order.paid", "audit.login", "audit.login.failed", "user.created"},
+			expected: []bool{true, true, false, false, true},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := New(test.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Alternates between a draw every rate keeps and one only a rate of 1 keeps.
+			draws := 0
+			s.random = func() float64 {
+				draws++
+				if draws%2 == 1 {
+					return 0
+				}
+				return 0.99
+			}
+			for i, key := range test.keys {
+				if kept := s.Keep(key, now); kept != test.expected[i] {
+					t.Errorf("expected message %d with routing key %s to be kept %t, got %t", i, key, test.expected[i], kept)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	s, err := New(Options{PerSecond: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+	kept := 0
+	for range 10 {
+		if s.Keep("a", now) {
+			kept++
+		}
+	}
+	if kept != 2 {
+		t.Errorf("expected a burst of 2 messages, got %d", kept)
+	}
+	if !s.Keep("a", now.Add(500*time.Millisecond)) {
+		t.Error("expected a token to be refilled after half a second")
+	}
+	if s.Keep("a", now.Add(600*time.Millisecond)) {
+		t.Error("expected no token to be left")
+	}
+}
+
+func TestString(t *testing.T) {
+	s, err := New(Options{Rate: 0.25, Every: 10, Keys: []KeyRate{{Pattern: "order.#", Rate: 0.1}}, PerSecond: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "1 in 10, 25%, order.#=10%, 100/s"; s.String() != expected {
+		t.Errorf("expected %q, got %q", expected, s.String())
+	}
+}
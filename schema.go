@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/fatih/color"
+	failed "github.com/ghokun/coyote/error"
+	"github.com/ghokun/coyote/predicate"
+	"github.com/ghokun/coyote/schema"
+	"github.com/ghokun/coyote/store"
+	"github.com/urfave/cli/v3"
+)
+
+const schemaUsage = `coyote schema [options]
+
+Infers a JSON Schema per exchange and routing key from the JSON bodies of a store. Field types, required fields,
+enums of strings taking a few repeated values, and nested objects and arrays are inferred. Bodies that are not JSON
+are skipped.
+
+Schemas are printed as a JSON object keyed by exchange and routing key, or written into --output-dir as
+<exchange>/<routing key>.schema.json, the default exchange being named amq.default. Names are escaped as in urls.
+With --check, drift from checked-in schemas is reported instead and coyote exits with 1 when there is any.
+A checked-in directory holds schemas named as written by --output-dir, a file is checked against every routing key.
+
+Examples:
+# Write the inferred schemas of every routing key into a directory
+coyote schema --store events.sqlite --output-dir schemas
+
+# Report drift of captured messages from the checked-in schemas
+coyote schema --store events.sqlite --check schemas
+
+# Check order.created events against a single schema file
+coyote schema --store events.sqlite --where 'routing-key=order.created' --check order-created.schema.json`
+
+func schemaCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "schema",
+		Usage:     "Infers JSON Schemas of stored bodies per routing key and reports drift from checked-in ones.",
+		UsageText: schemaUsage,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "store",
+				Usage: "SQLite filename to read events from.",
+			},
+			&cli.StringSliceFlag{
+				Name:  "where",
+				Usage: "Infers schemas of events matching one of the given predicates only, written as for coyote expect.",
+			},
+			&cli.IntFlag{
+				Name:  "max-enum",
+				Value: 5,
+				Usage: "Largest number of distinct values of a string field inferred as an enum, 0 infers none.",
+			},
+			&cli.StringFlag{
+				Name:  "output-dir",
+				Usage: "Directory to write a schema file per exchange and routing key into.",
+			},
+			&cli.StringFlag{
+				Name:  "check",
+				Usage: "Checked-in schema file or directory to report drift from.",
+			},
+		},
+		Action: inferSchemas,
+	}
+}
+
+// schemaGroup is the exchange & routing key pair a schema is inferred for.
+type schemaGroup struct {
+	exchange   string
+	routingKey string
+}
+
+func (g schemaGroup) title() string {
+	return displayExchange(g.exchange) + " " + g.routingKey
+}
+
+// filename names the schema file of the group in a directory per exchange, since exchange names and routing keys
+// both hold dots.
+func (g schemaGroup) filename() string {
+	exchange := g.exchange
+	if exchange == "" {
+		exchange = "amq.default"
+	}
+	return filepath.Join(pathSegment(exchange), pathSegment(g.routingKey)+".schema.json")
+}
+
+// pathSegment escapes a name into a file name of its own, which is never . or .. either.
+func pathSegment(name string) string {
+	if name == "." || name == ".." {
+		return strings.ReplaceAll(name, ".", "%2E")
+	}
+	return url.PathEscape(name)
+}
+
+func inferSchemas(_ context.Context, cli *cli.Command) error {
+	if !cli.IsSet("store") {
+		return failed.Because("store must be set", nil)
+	}
+	var predicates []*predicate.Predicate
+	for _, text := range cli.StringSlice("where") {
+		p, err := predicate.Parse(text)
+		if err != nil {
+			return err
+		}
+		predicates = append(predicates, p)
+	}
+
+	inferrers := map[schemaGroup]*schema.Inferrer{}
+	skipped := 0
+	if err := readStore(cli, cli.String("store"), func(e store.Event) error {
+		if !matchesAny(predicates, e) {
+			return nil
+		}
+		group := schemaGroup{exchange: e.Exchange, routingKey: e.RoutingKey}
+		if inferrers[group] == nil {
+			inferrers[group] = schema.NewInferrer(cli.Int("max-enum"))
+		}
+		if err := inferrers[group].Observe(e.Body); err != nil {
+			skipped++
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if skipped > 0 {
+		log.Printf("⚠️ Skipped %s bodies that are not JSON", color.YellowString("%d", skipped))
+	}
+	var groups []schemaGroup
+	for group, i := range inferrers {
+		if i.Documents() > 0 {
+			groups = append(groups, group)
+		}
+	}
+	slices.SortFunc(groups, func(a, b schemaGroup) int {
+		return strings.Compare(a.exchange+"\x00"+a.routingKey, b.exchange+"\x00"+b.routingKey)
+	})
+
+	switch {
+	case cli.IsSet("check"):
+		return checkSchemas(cli.String("check"), groups, inferrers, cli.Root().Writer)
+	case cli.IsSet("output-dir"):
+		return writeSchemas(cli.String("output-dir"), groups, inferrers)
+	default:
+		schemas := map[string]*schema.Schema{}
+		for _, group := range groups {
+			schemas[group.title()] = inferrers[group].Schema(group.title())
+		}
+		encoder := json.NewEncoder(cli.Root().Writer)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(schemas)
+	}
+}
+
+func writeSchemas(dir string, groups []schemaGroup, inferrers map[schemaGroup]*schema.Inferrer) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return failed.Because("failed to create "+dir, err)
+	}
+	for _, group := range groups {
+		encoded, err := json.MarshalIndent(inferrers[group].Schema(group.title()), "", "  ")
+		if err != nil {
+			return failed.Because("failed to encode schema of "+group.title(), err)
+		}
+		path := filepath.Join(dir, group.filename())
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return failed.Because("failed to create "+filepath.Dir(path), err)
+		}
+		if err := os.WriteFile(path, append(encoded, '\n'), 0o644); err != nil {
+			return failed.Because("failed to write "+path, err)
+		}
+		log.Printf("📐 Inferred schema of %s from %s messages into %s",
+			color.YellowString(group.title()), color.GreenString("%d", inferrers[group].Documents()), color.YellowString(path))
+	}
+	return nil
+}
+
+// checkSchemas reports the drift of every group from its checked-in schema, exiting with 1 when there is any.
+func checkSchemas(check string, groups []schemaGroup, inferrers map[schemaGroup]*schema.Inferrer, w io.Writer) error {
+	info, err := os.Stat(check)
+	if err != nil {
+		return failed.Because("failed to read "+check, err)
+	}
+	drifted := 0
+	for _, group := range groups {
+		path := check
+		if info.IsDir() {
+			path = filepath.Join(check, group.filename())
+		}
+		content, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			_, _ = fmt.Fprintln(w, color.GreenString("+ %s", group.title()), "has no checked-in schema", path)
+			drifted++
+			continue
+		}
+		if err != nil {
+			return failed.Because("failed to read "+path, err)
+		}
+		expected, err := schema.Parse(content)
+		if err != nil {
+			return failed.Because("failed to read "+path, err)
+		}
+		changes := inferrers[group].Drift(expected)
+		if len(changes) == 0 {
+			continue
+		}
+		drifted++
+		_, _ = fmt.Fprintln(w, color.YellowString("~ %s", group.title()), "drifted from", path)
+		for _, c := range changes {
+			field := c.Path
+			if field == "" {
+				field = "(body)"
+			}
+			switch {
+			case c.Expected != "" && c.Actual != "":
+				_, _ = fmt.Fprintf(w, "    %s: %s, %s → %s\n", field, c.Kind, color.RedString(c.Expected), color.GreenString(c.Actual))
+			case c.Expected != "":
+				_, _ = fmt.Fprintf(w, "    %s: %s, %s\n", field, c.Kind, color.RedString(c.Expected))
+			default:
+				_, _ = fmt.Fprintf(w, "    %s: %s, %s\n", field, c.Kind, color.GreenString(c.Actual))
+			}
+		}
+	}
+	log.Printf("📐 %s of %s routing keys drifted from their schemas",
+		color.YellowString("%d", drifted), color.YellowString("%d", len(groups)))
+	if drifted > 0 {
+		return differencesFound()
+	}
+	return nil
+}
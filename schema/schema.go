@@ -0,0 +1,277 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	failed "github.com/ghokun/coyote/error"
+)
+
+// Draft is the JSON Schema version inferred schemas declare.
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is the subset of JSON Schema inferred from bodies and compared for drift. Other keywords of checked-in
+// schemas are ignored.
+type Schema struct {
+	Schema     string             `json:"$schema,omitempty"`
+	Title      string             `json:"title,omitempty"`
+	Type       Types              `json:"type,omitempty"`
+	Properties map[string]*Schema `json:"properties,omitempty"`
+	Required   []string           `json:"required,omitempty"`
+	Items      *Schema            `json:"items,omitempty"`
+	Enum       []any              `json:"enum,omitempty"`
+}
+
+// Types are the JSON types a value may have, written as a single type when there is only one.
+type Types []string
+
+func (t Types) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+func (t *Types) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = Types{single}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(t))
+}
+
+// allows reports whether a value of the given type conforms to the types, integers being numbers too.
+func (t Types) allows(kind string) bool {
+	return len(t) == 0 || slices.Contains(t, kind) || (kind == "integer" && slices.Contains(t, "number"))
+}
+
+// Parse reads a JSON Schema.
+func Parse(data []byte) (*Schema, error) {
+	var s Schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, failed.Because("failed to read schema", err)
+	}
+	return &s, nil
+}
+
+// Inferrer infers the schema of the JSON documents it observes.
+type Inferrer struct {
+	root    *node
+	maxEnum int
+}
+
+// node accumulates the values seen at a path of the documents.
+type node struct {
+	count      int
+	types      map[string]int
+	values     map[string]string
+	objects    int
+	properties map[string]*node
+	items      *node
+}
+
+func newNode() *node {
+	return &node{types: map[string]int{}, values: map[string]string{}, properties: map[string]*node{}}
+}
+
+// NewInferrer infers enums for strings whose values repeat and take at most maxEnum distinct values, 0 infers none.
+func NewInferrer(maxEnum int) *Inferrer {
+	return &Inferrer{root: newNode(), maxEnum: maxEnum}
+}
+
+// Observe adds a document to the inferred schema, it fails for bodies that are not JSON.
+func (i *Inferrer) Observe(body []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var document any
+	if err := decoder.Decode(&document); err != nil {
+		return failed.Because("body is not JSON", err)
+	}
+	i.root.observe(document, i.maxEnum)
+	return nil
+}
+
+// Documents returns the number of documents observed.
+func (i *Inferrer) Documents() int {
+	return i.root.count
+}
+
+func (n *node) observe(value any, maxEnum int) {
+	n.count++
+	kind := kindOf(value)
+	n.types[kind]++
+	switch v := value.(type) {
+	case map[string]any:
+		n.objects++
+		for name, field := range v {
+			if n.properties[name] == nil {
+				n.properties[name] = newNode()
+			}
+			n.properties[name].observe(field, maxEnum)
+		}
+	case []any:
+		if n.items == nil {
+			n.items = newNode()
+		}
+		for _, item := range v {
+			n.items.observe(item, maxEnum)
+		}
+	case string:
+		// Distinct values are only kept while they may still become an enum.
+		if n.values != nil {
+			n.values[v] = v
+			if len(n.values) > maxEnum {
+				n.values = nil
+			}
+		}
+	}
+}
+
+func kindOf(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if strings.ContainsAny(v.String(), ".eE") {
+			return "number"
+		}
+		return "integer"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// Schema returns the schema inferred from the documents observed so far.
+func (i *Inferrer) Schema(title string) *Schema {
+	s := i.root.schema()
+	s.Schema, s.Title = Draft, title
+	return s
+}
+
+// kinds are the types of the values seen, integers are numbers when other numbers were seen too.
+func (n *node) kinds() Types {
+	var kinds Types
+	for _, kind := range []string{"null", "boolean", "integer", "number", "string", "array", "object"} {
+		if n.types[kind] > 0 && !(kind == "integer" && n.types["number"] > 0) {
+			kinds = append(kinds, kind)
+		}
+	}
+	return kinds
+}
+
+func (n *node) schema() *Schema {
+	s := &Schema{Type: n.kinds()}
+	if n.objects > 0 {
+		s.Properties = map[string]*Schema{}
+		for name, property := range n.properties {
+			s.Properties[name] = property.schema()
+			// Fields present in every object are required, null values count as present.
+			if property.count == n.objects {
+				s.Required = append(s.Required, name)
+			}
+		}
+		slices.Sort(s.Required)
+	}
+	if n.items != nil && n.items.count > 0 {
+		s.Items = n.items.schema()
+	}
+	if len(s.Type) == 1 && s.Type[0] == "string" && n.values != nil && len(n.values) > 0 && n.count > len(n.values) {
+		for _, value := range slices.Sorted(maps.Keys(n.values)) {
+			s.Enum = append(s.Enum, value)
+		}
+	}
+	return s
+}
+
+// Change is a difference between an expected schema and the one inferred from actual documents.
+type Change struct {
+	// Path is the dot separated path of the field, a * segment stands for array items.
+	Path     string `json:"path"`
+	Kind     string `json:"kind"`
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+}
+
+// Kinds of changes.
+const (
+	NewField     = "new field"
+	MissingField = "missing field"
+	TypeChanged  = "type changed"
+	Optional     = "optional"
+	EnumChanged  = "enum changed"
+)
+
+// Drift lists how the documents observed so far depart from the expected schema, ordered by path.
+func (i *Inferrer) Drift(expected *Schema) []Change {
+	var changes []Change
+	i.root.drift(nil, expected, &changes)
+	slices.SortStableFunc(changes, func(a, b Change) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	return changes
+}
+
+func (n *node) drift(path []string, expected *Schema, changes *[]Change) {
+	at := strings.Join(path, ".")
+	kinds := n.kinds()
+	if slices.ContainsFunc(kinds, func(kind string) bool { return !expected.Type.allows(kind) }) {
+		*changes = append(*changes, Change{Path: at, Kind: TypeChanged, Expected: strings.Join(expected.Type, "|"), Actual: strings.Join(kinds, "|")})
+	}
+	if len(expected.Enum) > 0 && n.types["string"] > 0 {
+		if n.values == nil {
+			*changes = append(*changes, Change{Path: at, Kind: EnumChanged, Expected: values(expected.Enum), Actual: "too many distinct values"})
+		} else {
+			var outside []any
+			for _, value := range slices.Sorted(maps.Keys(n.values)) {
+				if !slices.Contains(expected.Enum, any(value)) {
+					outside = append(outside, value)
+				}
+			}
+			if len(outside) > 0 {
+				*changes = append(*changes, Change{Path: at, Kind: EnumChanged, Expected: values(expected.Enum), Actual: values(outside)})
+			}
+		}
+	}
+	if n.objects > 0 {
+		for _, name := range slices.Sorted(maps.Keys(n.properties)) {
+			field := strings.Join(append(slices.Clone(path), name), ".")
+			property := n.properties[name]
+			if expected.Properties[name] == nil {
+				*changes = append(*changes, Change{Path: field, Kind: NewField, Actual: strings.Join(property.kinds(), "|")})
+				continue
+			}
+			if slices.Contains(expected.Required, name) && property.count < n.objects {
+				*changes = append(*changes, Change{Path: field, Kind: Optional, Expected: "required", Actual: fmt.Sprintf("absent in %d of %d", n.objects-property.count, n.objects)})
+			}
+			property.drift(append(slices.Clone(path), name), expected.Properties[name], changes)
+		}
+		// Optional fields may just not have been seen yet, only required ones are missing.
+		for _, name := range expected.Required {
+			if n.properties[name] == nil {
+				*changes = append(*changes, Change{Path: strings.Join(append(slices.Clone(path), name), "."), Kind: MissingField, Expected: "required"})
+			}
+		}
+	}
+	if n.items != nil && n.items.count > 0 && expected.Items != nil {
+		n.items.drift(append(slices.Clone(path), "*"), expected.Items, changes)
+	}
+}
+
+func values(enum []any) string {
+	text := make([]string, len(enum))
+	for i, value := range enum {
+		text[i] = fmt.Sprint(value)
+	}
+	return strings.Join(text, "|")
+}
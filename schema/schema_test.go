@@ -0,0 +1,87 @@
+package schema
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func infer(t *testing.T, maxEnum int, bodies ...string) *Inferrer {
+	t.Helper()
+	i := NewInferrer(maxEnum)
+	for _, body := range bodies {
+		if err := i.Observe([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return i
+}
+
+func TestInfer(t *testing.T) {
+	i := infer(t, 3,
+		`{"id":1,"status":"paid","amount":10,"items":[{"sku":"a","qty":1}],"customer":{"name":"Jane"},"note":null}`,
+		`{"id":2,"status":"shipped","amount":12.5,"items":[{"sku":"b"}],"customer":{"name":"John","vip":true}}`,
+		`{"id":3,"status":"paid","amount":7,"items":[],"customer":{"name":"Joe"},"note":"fragile"}`,
+	)
+	if err := i.Observe([]byte("not json")); err == nil {
+		t.Error("expected a body that is not JSON to fail")
+	}
+	if i.Documents() != 3 {
+		t.Errorf("expected 3 documents, got %d", i.Documents())
+	}
+	encoded, err := json.Marshal(i.Schema("orders order.created"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"$schema":"https://json-schema.org/draft/2020-12/schema","title":"orders order.created","type":"object",` +
+		`"properties":{"amount":{"type":"number"},` +
+		`"customer":{"type":"object","properties":{"name":{"type":"string"},"vip":{"type":"boolean"}},"required":["name"]},` +
+		`"id":{"type":"integer"},` +
+		`"items":{"type":"array","items":{"type":"object","properties":{"qty":{"type":"integer"},"sku":{"type":"string"}},"required":["sku"]}},` +
+		`"note":{"type":["null","string"]},` +
+		`"status":{"type":"string","enum":["paid","shipped"]}},` +
+		`"required":["amount","customer","id","items","status"]}`
+	if string(encoded) != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, encoded)
+	}
+
+	parsed, err := Parse(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes := i.Drift(parsed); len(changes) != 0 {
+		t.Errorf("expected no drift from the inferred schema, got %+v", changes)
+	}
+}
+
+func TestDrift(t *testing.T) {
+	expected, err := Parse([]byte(`{"type":"object","required":["id","status","total"],"properties":{
+	  "id":{"type":"integer"},
+	  "status":{"type":"string","enum":["paid","shipped"]},
+	  "total":{"type":"number"},
+	  "items":{"type":"array","items":{"type":"object","properties":{"sku":{"type":"string"}}}}
+	}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := infer(t, 5,
+		`{"id":"A1","status":"refunded","items":[{"sku":"a","qty":1}],"coupon":"X"}`,
+		`{"id":"A2","status":"paid","total":3,"items":[]}`,
+	)
+	changes := i.Drift(expected)
+	expectedChanges := []Change{
+		{Path: "coupon", Kind: NewField, Actual: "string"},
+		{Path: "id", Kind: TypeChanged, Expected: "integer", Actual: "string"},
+		{Path: "items.*.qty", Kind: NewField, Actual: "integer"},
+		{Path: "status", Kind: EnumChanged, Expected: "paid|shipped", Actual: "refunded"},
+		{Path: "total", Kind: Optional, Expected: "required", Actual: "absent in 1 of 2"},
+	}
+	if !reflect.DeepEqual(changes, expectedChanges) {
+		t.Errorf("expected %+v, got %+v", expectedChanges, changes)
+	}
+
+	missing := infer(t, 5, `{"id":1,"status":"paid"}`)
+	if changes := missing.Drift(expected); !reflect.DeepEqual(changes, []Change{{Path: "total", Kind: MissingField, Expected: "required"}}) {
+		t.Errorf("expected total to be missing, got %+v", changes)
+	}
+}
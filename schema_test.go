@@ -0,0 +1,24 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSchemaFilename(t *testing.T) {
+	tests := []struct {
+		group    schemaGroup
+		expected string
+	}{
+		{schemaGroup{exchange: "orders.events", routingKey: "created"}, "orders.events/created.schema.json"},
+		{schemaGroup{exchange: "orders", routingKey: "events.created"}, "orders/events.created.schema.json"},
+		{schemaGroup{exchange: "", routingKey: "invoices"}, "amq.default/invoices.schema.json"},
+		{schemaGroup{exchange: "a/b", routingKey: `c\d e`}, "a%2Fb/c%5Cd%20e.schema.json"},
+		{schemaGroup{exchange: "..", routingKey: ".."}, "%2E%2E/%2E%2E.schema.json"},
+	}
+	for _, test := range tests {
+		if filename := test.group.filename(); filename != filepath.FromSlash(test.expected) {
+			t.Errorf("expected %s to be written to %s, got %s", test.group.title(), test.expected, filename)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package stats
+
+import (
+	"cmp"
+	"math"
+	"slices"
+)
+
+// Percentile returns the nearest-rank p-th percentile (0 < p <= 100) of values, which must be sorted.
+func Percentile[T cmp.Ordered](sorted []T, p float64) T {
+	var zero T
+	if len(sorted) == 0 {
+		return zero
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(0, min(rank, len(sorted))-1)]
+}
+
+// Summary holds the distribution of a set of values.
+type Summary[T cmp.Ordered] struct {
+	Count int `json:"count"`
+	Min   T   `json:"min"`
+	P50   T   `json:"p50"`
+	P90   T   `json:"p90"`
+	P99   T   `json:"p99"`
+	Max   T   `json:"max"`
+}
+
+// Summarize sorts values in place and returns their distribution.
+func Summarize[T cmp.Ordered](values []T) Summary[T] {
+	slices.Sort(values)
+	if len(values) == 0 {
+		return Summary[T]{}
+	}
+	return Summary[T]{
+		Count: len(values),
+		Min:   values[0],
+		P50:   Percentile(values, 50),
+		P90:   Percentile(values, 90),
+		P99:   Percentile(values, 99),
+		Max:   values[len(values)-1],
+	}
+}
@@ -0,0 +1,34 @@
+package stats
+
+import "testing"
+
+func TestPercentile(t *testing.T) {
+	values := []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	tests := []struct {
+		p        float64
+		expected int
+	}{
+		{1, 1},
+		{10, 1},
+		{50, 5},
+		{90, 9},
+		{99, 10},
+		{100, 10},
+	}
+	for _, test := range tests {
+		if got := Percentile(values, test.p); got != test.expected {
+			t.Errorf("p%v: expected %d, got %d", test.p, test.expected, got)
+		}
+	}
+	if got := Percentile([]int{}, 50); got != 0 {
+		t.Errorf("expected zero value for empty values, got %d", got)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	summary := Summarize([]int{5, 3, 1, 4, 2})
+	expected := Summary[int]{Count: 5, Min: 1, P50: 3, P90: 5, P99: 5, Max: 5}
+	if summary != expected {
+		t.Errorf("expected %+v, got %+v", expected, summary)
+	}
+}
@@ -0,0 +1,165 @@
+package stats
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"time"
+)
+
+// Message is what the traffic profile observes of a message.
+type Message struct {
+	Time        time.Time
+	Exchange    string
+	RoutingKey  string
+	ContentType string
+	AppId       string
+	UserId      string
+	HeaderKeys  []string
+	Size        int
+}
+
+// Traffic profiles the messages flowing through exchanges. Body sizes and inter-arrival times are kept
+// for their percentiles, so memory grows with the number of observed messages.
+type Traffic struct {
+	start        time.Time
+	total        *flow
+	keys         map[Key]*flow
+	contentTypes map[string]int
+	headers      map[string]int
+	appIds       map[string]int
+	userIds      map[string]int
+}
+
+// Key is an exchange & routing key pair.
+type Key struct {
+	Exchange   string `json:"exchange"`
+	RoutingKey string `json:"routing_key"`
+}
+
+type flow struct {
+	sizes        []int
+	bytes        int64
+	last         time.Time
+	interArrival []time.Duration
+}
+
+func (f *flow) observe(m Message) {
+	if !f.last.IsZero() {
+		f.interArrival = append(f.interArrival, max(m.Time.Sub(f.last), 0))
+	}
+	f.last = m.Time
+	f.sizes = append(f.sizes, m.Size)
+	f.bytes += int64(m.Size)
+}
+
+// NewTraffic starts profiling at start, which rates are measured from. A zero start begins with the earliest message.
+func NewTraffic(start time.Time) *Traffic {
+	return &Traffic{
+		start:        start,
+		total:        &flow{},
+		keys:         map[Key]*flow{},
+		contentTypes: map[string]int{},
+		headers:      map[string]int{},
+		appIds:       map[string]int{},
+		userIds:      map[string]int{},
+	}
+}
+
+// Observe adds a message to the profile.
+func (t *Traffic) Observe(m Message) {
+	if t.start.IsZero() || m.Time.Before(t.start) {
+		t.start = m.Time
+	}
+	t.total.observe(m)
+	key := Key{Exchange: m.Exchange, RoutingKey: m.RoutingKey}
+	if t.keys[key] == nil {
+		t.keys[key] = &flow{}
+	}
+	t.keys[key].observe(m)
+	t.contentTypes[m.ContentType]++
+	t.appIds[m.AppId]++
+	t.userIds[m.UserId]++
+	for _, header := range m.HeaderKeys {
+		t.headers[header]++
+	}
+}
+
+// Flow is the traffic of all messages or of an exchange & routing key pair. Rates are messages per second.
+type Flow struct {
+	Messages     int                    `json:"messages"`
+	Bytes        int64                  `json:"bytes"`
+	Rate         float64                `json:"rate"`
+	BodySize     Summary[int]           `json:"body_size"`
+	InterArrival Summary[time.Duration] `json:"inter_arrival_ns"`
+}
+
+// KeyFlow is the traffic of an exchange & routing key pair.
+type KeyFlow struct {
+	Key
+	Flow
+}
+
+// Count is how many messages had a value, empty values are messages without it.
+type Count struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// Report is the traffic profile up to a point in time. Keys are ordered by their number of messages,
+// counts by how often they occurred.
+type Report struct {
+	Start        time.Time `json:"start"`
+	End          time.Time `json:"end"`
+	Total        Flow      `json:"total"`
+	Keys         []KeyFlow `json:"keys"`
+	ContentTypes []Count   `json:"content_types"`
+	Headers      []Count   `json:"headers"`
+	AppIds       []Count   `json:"app_ids"`
+	UserIds      []Count   `json:"user_ids"`
+}
+
+// Report profiles the messages observed until end.
+func (t *Traffic) Report(end time.Time) Report {
+	seconds := end.Sub(t.start).Seconds()
+	r := Report{
+		Start:        t.start,
+		End:          end,
+		Total:        t.total.report(seconds),
+		ContentTypes: counts(t.contentTypes),
+		Headers:      counts(t.headers),
+		AppIds:       counts(t.appIds),
+		UserIds:      counts(t.userIds),
+	}
+	for key, f := range t.keys {
+		r.Keys = append(r.Keys, KeyFlow{Key: key, Flow: f.report(seconds)})
+	}
+	slices.SortFunc(r.Keys, func(a, b KeyFlow) int {
+		return cmp.Or(cmp.Compare(b.Messages, a.Messages), cmp.Compare(a.Exchange, b.Exchange), cmp.Compare(a.RoutingKey, b.RoutingKey))
+	})
+	return r
+}
+
+func (f *flow) report(seconds float64) Flow {
+	report := Flow{
+		Messages:     len(f.sizes),
+		Bytes:        f.bytes,
+		BodySize:     Summarize(slices.Clone(f.sizes)),
+		InterArrival: Summarize(slices.Clone(f.interArrival)),
+	}
+	if seconds > 0 {
+		report.Rate = float64(report.Messages) / seconds
+	}
+	return report
+}
+
+func counts(values map[string]int) []Count {
+	var c []Count
+	for _, value := range slices.Sorted(maps.Keys(values)) {
+		c = append(c, Count{Value: value, Count: values[value]})
+	}
+	slices.SortStableFunc(c, func(a, b Count) int {
+		return cmp.Compare(b.Count, a.Count)
+	})
+	return c
+}
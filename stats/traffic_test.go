@@ -0,0 +1,47 @@
+package stats
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTraffic(t *testing.T) {
+	start := time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+	traffic := NewTraffic(time.Time{})
+	for _, m := range []Message{
+		{Time: start, Exchange: "orders", RoutingKey: "order.created", ContentType: "application/json", AppId: "shop", HeaderKeys: []string{"tenant"}, Size: 10},
+		{Time: start.Add(time.Second), Exchange: "orders", RoutingKey: "order.paid", ContentType: "application/json", AppId: "billing", HeaderKeys: []string{"tenant", "retries"}, Size: 20},
+		{Time: start.Add(3 * time.Second), Exchange: "orders", RoutingKey: "order.created", AppId: "shop", Size: 30},
+		{Time: start.Add(4 * time.Second), Exchange: "orders", RoutingKey: "order.created", ContentType: "text/plain", AppId: "shop", UserId: "guest", Size: 40},
+	} {
+		traffic.Observe(m)
+	}
+	report := traffic.Report(start.Add(4 * time.Second))
+
+	if expected := (Flow{
+		Messages:     4,
+		Bytes:        100,
+		Rate:         1,
+		BodySize:     Summary[int]{Count: 4, Min: 10, P50: 20, P90: 40, P99: 40, Max: 40},
+		InterArrival: Summary[time.Duration]{Count: 3, Min: time.Second, P50: time.Second, P90: 2 * time.Second, P99: 2 * time.Second, Max: 2 * time.Second},
+	}); report.Total != expected {
+		t.Errorf("expected total %+v, got %+v", expected, report.Total)
+	}
+	if len(report.Keys) != 2 || report.Keys[0].RoutingKey != "order.created" || report.Keys[0].Messages != 3 || report.Keys[0].Rate != 0.75 ||
+		report.Keys[0].InterArrival.Max != 3*time.Second || report.Keys[1].RoutingKey != "order.paid" || report.Keys[1].InterArrival.Count != 0 {
+		t.Errorf("unexpected keys %+v", report.Keys)
+	}
+	if expected := []Count{{"application/json", 2}, {"", 1}, {"text/plain", 1}}; !reflect.DeepEqual(report.ContentTypes, expected) {
+		t.Errorf("expected content types %v, got %v", expected, report.ContentTypes)
+	}
+	if expected := []Count{{"tenant", 2}, {"retries", 1}}; !reflect.DeepEqual(report.Headers, expected) {
+		t.Errorf("expected headers %v, got %v", expected, report.Headers)
+	}
+	if expected := []Count{{"shop", 3}, {"billing", 1}}; !reflect.DeepEqual(report.AppIds, expected) {
+		t.Errorf("expected app ids %v, got %v", expected, report.AppIds)
+	}
+	if expected := []Count{{"", 3}, {"guest", 1}}; !reflect.DeepEqual(report.UserIds, expected) {
+		t.Errorf("expected user ids %v, got %v", expected, report.UserIds)
+	}
+}
@@ -0,0 +1,139 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"github.com/ghokun/coyote/encryption"
+	failed "github.com/ghokun/coyote/error"
+)
+
+// sealedPrefix marks encrypted cells, which hold the sealed value encoded in base64.
+const sealedPrefix = "enc:"
+
+// keyCheck is sealed when a store is encrypted, so that a wrong key is told apart from corrupted events.
+const keyCheck = "coyote"
+
+// ErrLocked is returned when an encrypted store is written or read without its key.
+var ErrLocked = errors.New("store is encrypted, a key is required")
+
+// encryptionParams are what the key of an encrypted store is derived and checked with.
+type encryptionParams struct {
+	salt       []byte
+	iterations int
+	check      []byte
+}
+
+// readEncryption returns the encryption parameters of the store, or nil for stores that are not encrypted.
+func readEncryption(db *sql.DB) (*encryptionParams, error) {
+	var tables int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'encryption'`).Scan(&tables); err != nil {
+		return nil, failed.Because("failed to read encryption table", err)
+	}
+	if tables == 0 {
+		return nil, nil
+	}
+	params := &encryptionParams{}
+	if err := db.QueryRow(`SELECT salt, iterations, key_check FROM encryption`).Scan(&params.salt, &params.iterations, &params.check); err != nil {
+		return nil, failed.Because("failed to read encryption table", err)
+	}
+	return params, nil
+}
+
+// Encrypted reports whether the headers, bodies and validations of the store are encrypted.
+func (s *Store) Encrypted() bool {
+	return s.encryption != nil
+}
+
+// Unlock derives the key of the store from the secret, so that its headers and bodies are encrypted when written and
+// decrypted when read. A store without events opened for writing is encrypted with it, stores holding unencrypted
+// events cannot be. Reading a store that is not encrypted needs no key, so the secret is ignored.
+func (s *Store) Unlock(secret []byte) error {
+	if s.encryption == nil {
+		if s.insert == nil {
+			return nil
+		}
+		var events int
+		if err := s.db.QueryRow(`SELECT COUNT(*) FROM event`).Scan(&events); err != nil {
+			return failed.Because("failed to count events", err)
+		}
+		if events > 0 {
+			return failed.Because("store holds unencrypted events and cannot be encrypted", nil)
+		}
+		return s.encrypt(secret)
+	}
+	key, err := encryption.Derive(secret, s.encryption.salt, s.encryption.iterations)
+	if err != nil {
+		return err
+	}
+	if check, err := key.Open(s.encryption.check, "key_check"); err != nil || string(check) != keyCheck {
+		return failed.Because("wrong key for store", nil)
+	}
+	s.key = key
+	return nil
+}
+
+func (s *Store) encrypt(secret []byte) error {
+	salt, err := encryption.NewSalt()
+	if err != nil {
+		return err
+	}
+	key, err := encryption.Derive(secret, salt, encryption.Iterations)
+	if err != nil {
+		return err
+	}
+	check, err := key.Seal([]byte(keyCheck), "key_check")
+	if err != nil {
+		return err
+	}
+	params := &encryptionParams{salt: salt, iterations: encryption.Iterations, check: check}
+	if _, err := s.db.Exec(`CREATE TABLE encryption ("salt" BLOB, "iterations" INTEGER, "key_check" BLOB)`); err != nil {
+		return failed.Because("failed to create encryption table", err)
+	}
+	if _, err := s.db.Exec(`INSERT INTO encryption(salt, iterations, key_check) VALUES (?, ?, ?)`, params.salt, params.iterations, params.check); err != nil {
+		return failed.Because("failed to write encryption table", err)
+	}
+	s.encryption, s.key = params, key
+	return nil
+}
+
+// seal encrypts the value of a column when the store is encrypted.
+func (s *Store) seal(value string, column string) (string, error) {
+	if s.encryption == nil {
+		return value, nil
+	}
+	if s.key == nil {
+		return "", ErrLocked
+	}
+	sealed, err := s.key.Seal([]byte(value), column)
+	if err != nil {
+		return "", err
+	}
+	return sealedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// open decrypts the value of a column that was sealed. Values of stores that are not encrypted are never sealed, even
+// when they happen to start like sealed ones, while every value of an encrypted store is.
+func (s *Store) open(value string, column string) (string, error) {
+	if s.encryption == nil {
+		return value, nil
+	}
+	if s.key == nil {
+		return "", ErrLocked
+	}
+	encoded, found := strings.CutPrefix(value, sealedPrefix)
+	if !found {
+		return "", failed.Because(column+" of encrypted store is not encrypted", nil)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", failed.Because("failed to decode encrypted "+column, err)
+	}
+	plaintext, err := s.key.Open(sealed, column)
+	if err != nil {
+		return "", failed.Because("failed to decrypt "+column, err)
+	}
+	return string(plaintext), nil
+}
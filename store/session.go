@@ -0,0 +1,134 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+
+	failed "github.com/ghokun/coyote/error"
+)
+
+// Session describes a capture run. It is written when the capture starts and completed when it stops,
+// a capture killed by a second interrupt leaves it without a stop time.
+type Session struct {
+	ID             int64
+	Version        string
+	Hosts          []string
+	Vhosts         []string
+	Bindings       []Binding
+	Queue          string
+	QueueArguments map[string]any
+	AuthMethod     string
+	StartedAt      time.Time
+	StoppedAt      time.Time
+	// Messages and Bytes count every message received, including those Skipped by sampling or rate limiting.
+	Messages   int
+	Bytes      int64
+	Skipped    int
+	Sampling   string
+	ExitReason string
+}
+
+// addedSessionColumns are the session columns added after the session table with their types.
+var addedSessionColumns = [][2]string{
+	{"skipped", "INTEGER"},
+	{"sampling", "TEXT"},
+}
+
+// Binding is an exchange & routing key pair the interceptor queue of a session was bound with.
+type Binding struct {
+	Source     string `json:"source"`
+	Host       string `json:"host"`
+	Vhost      string `json:"vhost"`
+	Exchange   string `json:"exchange"`
+	RoutingKey string `json:"routing_key"`
+}
+
+func createSessionTable(db *sql.DB) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS session
+	(
+	  "id"              INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	  "version"         TEXT,
+	  "host"            TEXT,
+	  "vhost"           TEXT,
+	  "bindings"        TEXT,
+	  "queue"           TEXT,
+	  "queue_arguments" TEXT,
+	  "auth_method"     TEXT,
+	  "started_at"      TIMESTAMP,
+	  "stopped_at"      TIMESTAMP,
+	  "messages"        INTEGER,
+	  "bytes"           INTEGER,
+	  "exit_reason"     TEXT,
+	  "skipped"         INTEGER,
+	  "sampling"        TEXT
+	);`); err != nil {
+		return failed.Because("failed to create session table", err)
+	}
+	columns, err := tableColumns(db, "session")
+	if err != nil {
+		return err
+	}
+	for _, column := range addedSessionColumns {
+		name, definition := column[0], column[1]
+		if !columns[name] {
+			if _, err := db.Exec(`ALTER TABLE session ADD COLUMN "` + name + `" ` + definition); err != nil {
+				return failed.Because("failed to add column "+name+" to session table", err)
+			}
+		}
+	}
+	return nil
+}
+
+// StartSession records the start of a capture, events inserted afterward reference the session.
+func (s *Store) StartSession(session *Session) error {
+	bindings, err := json.Marshal(session.Bindings)
+	if err != nil {
+		return failed.Because("failed to encode session bindings", err)
+	}
+	arguments := session.QueueArguments
+	if arguments == nil {
+		arguments = map[string]any{}
+	}
+	queueArguments, err := json.Marshal(arguments)
+	if err != nil {
+		return failed.Because("failed to encode queue arguments", err)
+	}
+	session.StartedAt = time.Now()
+	result, err := s.db.Exec(`INSERT INTO session(version, host, vhost, bindings, queue, queue_arguments, auth_method, started_at, sampling)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		session.Version,
+		strings.Join(session.Hosts, ","),
+		strings.Join(session.Vhosts, ","),
+		string(bindings),
+		session.Queue,
+		string(queueArguments),
+		session.AuthMethod,
+		session.StartedAt.Format(timestampLayout),
+		session.Sampling)
+	if err != nil {
+		return failed.Because("failed to record session", err)
+	}
+	if session.ID, err = result.LastInsertId(); err != nil {
+		return failed.Because("failed to record session", err)
+	}
+	s.session = sql.NullInt64{Int64: session.ID, Valid: true}
+	return nil
+}
+
+// StopSession records the stop time, totals and exit reason of the session.
+func (s *Store) StopSession(session *Session) error {
+	session.StoppedAt = time.Now()
+	_, err := s.db.Exec(`UPDATE session SET stopped_at = ?, messages = ?, bytes = ?, skipped = ?, exit_reason = ? WHERE id = ?`,
+		session.StoppedAt.Format(timestampLayout),
+		session.Messages,
+		session.Bytes,
+		session.Skipped,
+		session.ExitReason,
+		session.ID)
+	if err != nil {
+		return failed.Because("failed to complete session", err)
+	}
+	return nil
+}
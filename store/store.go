@@ -0,0 +1,412 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ghokun/coyote/encryption"
+	failed "github.com/ghokun/coyote/error"
+	"github.com/rabbitmq/amqp091-go"
+	_ "modernc.org/sqlite"
+)
+
+// timestampLayout matches SQLite's DATETIME format with microseconds, so that latencies can be measured from stored events.
+const timestampLayout = "2006-01-02 15:04:05.000000"
+
+type Store struct {
+	db         *sql.DB
+	insert     *sql.Stmt
+	columns    map[string]bool
+	session    sql.NullInt64
+	encryption *encryptionParams
+	key        *encryption.Key
+}
+
+// Origin tells where a message was captured.
+type Origin struct {
+	Source string
+	Vhost  string
+}
+
+// addedColumns are the event columns added after the first release with their types, which older stores are migrated to.
+var addedColumns = [][2]string{
+	{"vhost", "TEXT"},
+	{"source", "TEXT"},
+	{"session_id", "INTEGER REFERENCES session(id)"},
+	{"message_id", "TEXT"},
+	{"redactions", "TEXT"},
+	{"content_type", "TEXT"},
+	{"app_id", "TEXT"},
+	{"user_id", "TEXT"},
+	{"validation", "TEXT"},
+}
+
+// Event is a message as it is stored in the event table.
+type Event struct {
+	ID            int64
+	SessionID     int64
+	Timestamp     time.Time
+	Source        string
+	Vhost         string
+	Exchange      string
+	RoutingKey    string
+	MessageId     string
+	CorrelationId string
+	ReplyTo       string
+	ContentType   string
+	AppId         string
+	UserId        string
+	Headers       string
+	Body          []byte
+	// Redactions names the redaction rules that fired on the message.
+	Redactions []string
+	// Validation is the result of validating the message against its contract, nil when none applied.
+	Validation *Validation
+}
+
+// Validation is the result of validating a message body against the contract of its exchange and routing key.
+type Validation struct {
+	Contract string   `json:"contract"`
+	Valid    bool     `json:"valid"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+// HeaderTable decodes the stored headers, it reports false for headers stored by older releases.
+func (e Event) HeaderTable() (headers amqp091.Table, ok bool) {
+	if err := json.Unmarshal([]byte(e.Headers), &headers); err != nil {
+		return nil, false
+	}
+	return headers, true
+}
+
+// Delivery rebuilds the delivery the event was stored from, so that it can be matched like a live one.
+func (e Event) Delivery() amqp091.Delivery {
+	headers, _ := e.HeaderTable()
+	return amqp091.Delivery{
+		Exchange:      e.Exchange,
+		RoutingKey:    e.RoutingKey,
+		MessageId:     e.MessageId,
+		CorrelationId: e.CorrelationId,
+		ReplyTo:       e.ReplyTo,
+		ContentType:   e.ContentType,
+		AppId:         e.AppId,
+		UserId:        e.UserId,
+		Headers:       headers,
+		Body:          e.Body,
+	}
+}
+
+func Open(filename string) (store *Store, err error) {
+	db, err := sql.Open("sqlite", filename+"?_txlock=exclusive&mode=rwc")
+	if err != nil {
+		return nil, failed.Because("failed to open store "+filename, err)
+	}
+	if err := createSessionTable(db); err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS event 
+	(
+	  "id"             INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+	  "timestamp"      TIMESTAMP DEFAULT (DATETIME(CURRENT_TIMESTAMP, 'localtime')),
+	  "exchange"       TEXT,
+	  "routing_key"    TEXT,
+	  "correlation_id" TEXT,
+	  "reply_to"       TEXT,
+	  "headers"        TEXT,
+	  "body"           TEXT,
+	  "vhost"          TEXT,
+	  "source"         TEXT,
+	  "session_id"     INTEGER REFERENCES session(id),
+	  "message_id"     TEXT,
+	  "redactions"     TEXT,
+	  "content_type"   TEXT,
+	  "app_id"         TEXT,
+	  "user_id"        TEXT,
+	  "validation"     TEXT
+	);`); err != nil {
+		return nil, failed.Because("failed to create event table", err)
+	}
+	columns, err := tableColumns(db, "event")
+	if err != nil {
+		return nil, err
+	}
+	for _, column := range addedColumns {
+		name, definition := column[0], column[1]
+		if !columns[name] {
+			if _, err := db.Exec(`ALTER TABLE event ADD COLUMN "` + name + `" ` + definition); err != nil {
+				return nil, failed.Because("failed to add column "+name+" to event table", err)
+			}
+			columns[name] = true
+		}
+	}
+	insert, err := db.Prepare(`INSERT INTO event(session_id, timestamp, source, vhost, exchange, routing_key, message_id, correlation_id, reply_to, headers, body, redactions, content_type, app_id, user_id, validation) 
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return nil, failed.Because("failed to prepare event insert", err)
+	}
+	params, err := readEncryption(db)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{db: db, insert: insert, columns: columns, encryption: params}, nil
+}
+
+// OpenExisting opens a store that was written before, for reading its events.
+func OpenExisting(filename string) (store *Store, err error) {
+	if _, err := os.Stat(filename); err != nil {
+		return nil, failed.Because("failed to open store "+filename, err)
+	}
+	// SQLite only reads the mode of URI filenames, whose paths are percent-decoded.
+	uri := "file:" + strings.NewReplacer("%", "%25", "?", "%3F", "#", "%23").Replace(filename) + "?mode=ro"
+	db, err := sql.Open("sqlite", uri)
+	if err != nil {
+		return nil, failed.Because("failed to open store "+filename, err)
+	}
+	columns, err := tableColumns(db, "event")
+	if err != nil {
+		return nil, err
+	}
+	params, err := readEncryption(db)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{db: db, columns: columns, encryption: params}, nil
+}
+
+// tableColumns returns the names of the columns of a table.
+func tableColumns(db *sql.DB, table string) (columns map[string]bool, err error) {
+	rows, err := db.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return nil, failed.Because("failed to read "+table+" table columns", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Println("⚠️ Failed to close column rows:", err)
+		}
+	}()
+	columns = map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, failed.Because("failed to read "+table+" table columns", err)
+		}
+		columns[name] = true
+	}
+	return columns, rows.Err()
+}
+
+// column selects the named column, or NULL for columns a store written by an older release lacks.
+func (s *Store) column(name string) string {
+	if s.columns[name] {
+		return name
+	}
+	return "NULL"
+}
+
+// Insert stores a captured delivery together with the names of the redaction rules that fired on it and the result of
+// validating it against its contract.
+func (s *Store) Insert(origin Origin, d amqp091.Delivery, redactions []string, validation *Validation) error {
+	headers, body, err := s.sealContent(EncodeHeaders(d.Headers), d.Body)
+	if err != nil {
+		return err
+	}
+	encodedValidation, err := s.encodeValidation(validation)
+	if err != nil {
+		return err
+	}
+	_, err = s.insert.Exec(s.session, time.Now().Format(timestampLayout), origin.Source, origin.Vhost, d.Exchange, d.RoutingKey, d.MessageId, d.CorrelationId, d.ReplyTo, headers, body, encodeRedactions(redactions), d.ContentType, d.AppId, d.UserId, encodedValidation)
+	return err
+}
+
+// sealContent encrypts the headers and body of a message when the store is encrypted.
+func (s *Store) sealContent(headers string, body []byte) (sealedHeaders string, sealedBody string, err error) {
+	if sealedHeaders, err = s.seal(headers, "headers"); err != nil {
+		return "", "", err
+	}
+	if sealedBody, err = s.seal(string(body), "body"); err != nil {
+		return "", "", err
+	}
+	return sealedHeaders, sealedBody, nil
+}
+
+// encodeRedactions stores rule names as a JSON array, leaving the column empty when none fired.
+func encodeRedactions(redactions []string) sql.NullString {
+	if len(redactions) == 0 {
+		return sql.NullString{}
+	}
+	encoded, err := json.Marshal(redactions)
+	if err != nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: string(encoded), Valid: true}
+}
+
+// encodeValidation stores a validation as a JSON object, leaving the column empty when no contract applied. It is sealed
+// like headers and bodies when the store is encrypted, as violations tell about the bodies they were found in.
+func (s *Store) encodeValidation(validation *Validation) (sql.NullString, error) {
+	if validation == nil {
+		return sql.NullString{}, nil
+	}
+	encoded, err := json.Marshal(validation)
+	if err != nil {
+		return sql.NullString{}, nil
+	}
+	sealed, err := s.seal(string(encoded), "validation")
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: sealed, Valid: true}, nil
+}
+
+// EncodeHeaders stores headers as a JSON object, so that they can be read back by name.
+// Stores written by older releases hold them as printed by fmt instead.
+func EncodeHeaders(headers amqp091.Table) string {
+	if len(headers) == 0 {
+		return "{}"
+	}
+	encoded, err := json.Marshal(headers)
+	if err != nil {
+		return fmt.Sprint(headers)
+	}
+	return string(encoded)
+}
+
+// InsertEvents stores events read from elsewhere in a single transaction, keeping their timestamps and origins.
+// Events are inserted as events calls insert, so that dumps larger than memory can be imported. They reference no session.
+func (s *Store) InsertEvents(events func(insert func(e Event) error) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return failed.Because("failed to begin transaction", err)
+	}
+	insert := tx.Stmt(s.insert)
+	err = events(func(e Event) error {
+		headers, body, err := s.sealContent(e.Headers, e.Body)
+		if err != nil {
+			return err
+		}
+		validation, err := s.encodeValidation(e.Validation)
+		if err != nil {
+			return err
+		}
+		_, err = insert.Exec(nil, e.Timestamp.In(time.Local).Format(timestampLayout), e.Source, e.Vhost, e.Exchange, e.RoutingKey, e.MessageId, e.CorrelationId, e.ReplyTo, headers, body, encodeRedactions(e.Redactions), e.ContentType, e.AppId, e.UserId, validation)
+		return err
+	})
+	if err != nil {
+		if err := tx.Rollback(); err != nil {
+			log.Println("⚠️ Failed to roll back transaction:", err)
+		}
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return failed.Because("failed to commit transaction", err)
+	}
+	return nil
+}
+
+// Filter narrows down the events read from a store, its zero value reads every event.
+type Filter struct {
+	SessionID int64
+	Since     time.Time
+	Until     time.Time
+}
+
+func (f Filter) where(s *Store) (clause string, args []any) {
+	var conditions []string
+	if f.SessionID != 0 {
+		conditions = append(conditions, s.column("session_id")+" = ?")
+		args = append(args, f.SessionID)
+	}
+	// Timestamps are stored in a sortable layout, so they compare as text.
+	if !f.Since.IsZero() {
+		conditions = append(conditions, "timestamp >= ?")
+		args = append(args, f.Since.In(time.Local).Format(timestampLayout))
+	}
+	if !f.Until.IsZero() {
+		conditions = append(conditions, "timestamp < ?")
+		args = append(args, f.Until.In(time.Local).Format(timestampLayout))
+	}
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// Events calls fn for every stored event matching the filter in the order they were captured, stopping at the first error.
+// Rows are read one at a time, so that stores larger than memory can be processed.
+func (s *Store) Events(filter Filter, fn func(e Event) error) error {
+	if s.encryption != nil && s.key == nil {
+		return ErrLocked
+	}
+	where, args := filter.where(s)
+	rows, err := s.db.Query(`SELECT id, `+s.column("session_id")+`, timestamp, `+s.column("source")+`, `+s.column("vhost")+`, exchange, routing_key, `+s.column("message_id")+`, correlation_id, reply_to, headers, body, `+s.column("redactions")+`, `+s.column("content_type")+`, `+s.column("app_id")+`, `+s.column("user_id")+`, `+s.column("validation")+` FROM event`+where+` ORDER BY id`, args...)
+	if err != nil {
+		return failed.Because("failed to query events", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Println("⚠️ Failed to close event rows:", err)
+		}
+	}()
+	for rows.Next() {
+		var e Event
+		var session sql.NullInt64
+		var source, vhost, exchange, routingKey, messageId, correlationId, replyTo, headers, body, redactions, contentType, appId, userId, validation sql.NullString
+		if err := rows.Scan(&e.ID, &session, &e.Timestamp, &source, &vhost, &exchange, &routingKey, &messageId, &correlationId, &replyTo, &headers, &body, &redactions, &contentType, &appId, &userId, &validation); err != nil {
+			return failed.Because("failed to read event", err)
+		}
+		// Timestamps are stored in local time without a zone, which the driver reads as UTC.
+		e.Timestamp = time.Date(e.Timestamp.Year(), e.Timestamp.Month(), e.Timestamp.Day(),
+			e.Timestamp.Hour(), e.Timestamp.Minute(), e.Timestamp.Second(), e.Timestamp.Nanosecond(), time.Local)
+		e.Source, e.Vhost, e.Exchange, e.RoutingKey, e.CorrelationId, e.ReplyTo = source.String, vhost.String, exchange.String, routingKey.String, correlationId.String, replyTo.String
+		e.MessageId, e.SessionID = messageId.String, session.Int64
+		e.ContentType, e.AppId, e.UserId = contentType.String, appId.String, userId.String
+		if e.Headers, err = s.open(headers.String, "headers"); err != nil {
+			return err
+		}
+		content, err := s.open(body.String, "body")
+		if err != nil {
+			return err
+		}
+		e.Body = []byte(content)
+		if redactions.Valid {
+			if err := json.Unmarshal([]byte(redactions.String), &e.Redactions); err != nil {
+				return failed.Because("failed to decode redactions of event "+strconv.FormatInt(e.ID, 10), err)
+			}
+		}
+		if validation.Valid {
+			encoded, err := s.open(validation.String, "validation")
+			if err != nil {
+				return err
+			}
+			if err := json.Unmarshal([]byte(encoded), &e.Validation); err != nil {
+				return failed.Because("failed to decode validation of event "+strconv.FormatInt(e.ID, 10), err)
+			}
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return failed.Because("failed to read events", err)
+	}
+	return nil
+}
+
+func (s *Store) Close() error {
+	if s.insert != nil {
+		if err := s.insert.Close(); err != nil {
+			return err
+		}
+	}
+	if err := s.db.Close(); err != nil {
+		return err
+	}
+	log.Printf("⛓️‍💥 Closing database connection")
+	return nil
+}
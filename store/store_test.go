@@ -0,0 +1,165 @@
+package store
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// events reads back the bodies and headers of every event of a store.
+func events(t *testing.T, s *Store) (bodies []string, headers []string, err error) {
+	t.Helper()
+	err = s.Events(Filter{}, func(e Event) error {
+		bodies, headers = append(bodies, string(e.Body)), append(headers, e.Headers)
+		return nil
+	})
+	return bodies, headers, err
+}
+
+func closeStore(t *testing.T, s *Store) {
+	t.Helper()
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPlainRoundTrip(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "events.sqlite")
+	s, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Values of stores that are not encrypted are read as they are, even when they look sealed.
+	d := amqp091.Delivery{Exchange: "orders", RoutingKey: "order.created", Headers: amqp091.Table{"token": "enc:abc"}, Body: []byte("enc:not-sealed")}
+	if err := s.Insert(Origin{}, d, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	closeStore(t, s)
+
+	s, err = OpenExisting(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeStore(t, s)
+	if s.Encrypted() {
+		t.Error("expected the store not to be encrypted")
+	}
+	bodies, headers, err := events(t, s)
+	if err != nil || len(bodies) != 1 || bodies[0] != "enc:not-sealed" || headers[0] != `{"token":"enc:abc"}` {
+		t.Errorf("expected the event back as it was stored, got %q %q, %v", bodies, headers, err)
+	}
+}
+
+func TestOpenExistingReadOnly(t *testing.T) {
+	// Percent signs and hashes are escaped in the URI the store is opened with.
+	filename := filepath.Join(t.TempDir(), "events 100%#1.sqlite")
+	s, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Insert(Origin{}, amqp091.Delivery{Exchange: "orders", RoutingKey: "order.created", Body: []byte("{}")}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	closeStore(t, s)
+
+	s, err = OpenExisting(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeStore(t, s)
+	if _, err := s.db.Exec(`DELETE FROM event`); err == nil {
+		t.Error("expected a store opened for reading to reject writes")
+	}
+	if bodies, _, err := events(t, s); err != nil || len(bodies) != 1 {
+		t.Errorf("expected the event to be read, got %q, %v", bodies, err)
+	}
+	if _, err := OpenExisting(filepath.Join(t.TempDir(), "missing.sqlite")); err == nil {
+		t.Error("expected a missing store to fail")
+	}
+}
+
+func TestSealedRoundTrip(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "events.sqlite")
+	s, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Unlock([]byte("secret")); err != nil {
+		t.Fatal(err)
+	}
+	validation := &Validation{Contract: "order.json", Errors: []string{"/id: type"}}
+	if err := s.Insert(Origin{}, amqp091.Delivery{Exchange: "orders", Headers: amqp091.Table{"tenant": "acme"}, Body: []byte(`{"id":1}`)}, nil, validation); err != nil {
+		t.Fatal(err)
+	}
+	var body, storedValidation string
+	if err := s.db.QueryRow(`SELECT body, validation FROM event`).Scan(&body, &storedValidation); err != nil || !strings.HasPrefix(body, sealedPrefix) || strings.Contains(body, `"id"`) {
+		t.Errorf("expected the body to be stored sealed, got %q, %v", body, err)
+	}
+	if !strings.HasPrefix(storedValidation, sealedPrefix) || strings.Contains(storedValidation, "/id") {
+		t.Errorf("expected the validation to be stored sealed, got %q", storedValidation)
+	}
+	closeStore(t, s)
+
+	s, err = OpenExisting(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeStore(t, s)
+	if !s.Encrypted() {
+		t.Error("expected the store to be encrypted")
+	}
+	if _, _, err := events(t, s); !errors.Is(err, ErrLocked) {
+		t.Errorf("expected reading without the key to fail, got %v", err)
+	}
+	if err := s.Unlock([]byte("other")); err == nil {
+		t.Error("expected a wrong key to fail")
+	}
+	if err := s.Unlock([]byte("secret")); err != nil {
+		t.Fatal(err)
+	}
+	bodies, headers, err := events(t, s)
+	if err != nil || len(bodies) != 1 || bodies[0] != `{"id":1}` || headers[0] != `{"tenant":"acme"}` {
+		t.Errorf("expected the event back decrypted, got %q %q, %v", bodies, headers, err)
+	}
+	err = s.Events(Filter{}, func(e Event) error {
+		if !reflect.DeepEqual(e.Validation, validation) {
+			t.Errorf("expected the validation back decrypted, got %+v", e.Validation)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUnsealedValueOfEncryptedStore(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "events.sqlite")
+	s, err := Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Unlock([]byte("secret")); err != nil {
+		t.Fatal(err)
+	}
+	// A row written without the key, e.g. by whoever can write to the file, is not authenticated and must not be read.
+	if _, err := s.db.Exec(`INSERT INTO event(exchange, routing_key, headers, body) VALUES ('orders', 'order.created', '{}', '{"id":1}')`); err != nil {
+		t.Fatal(err)
+	}
+	closeStore(t, s)
+
+	s, err = OpenExisting(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeStore(t, s)
+	if err := s.Unlock([]byte("secret")); err != nil {
+		t.Fatal(err)
+	}
+	if bodies, _, err := events(t, s); err == nil || !strings.Contains(err.Error(), "not encrypted") {
+		t.Errorf("expected an unsealed value to fail, got %q, %v", bodies, err)
+	}
+}
@@ -0,0 +1,180 @@
+package topology
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	failed "github.com/ghokun/coyote/error"
+	"github.com/ghokun/coyote/management"
+)
+
+const (
+	KindExchange = "exchange"
+	KindQueue    = "queue"
+)
+
+type Node struct {
+	ID          string `json:"id"`
+	Kind        string `json:"kind"`
+	Name        string `json:"name"`
+	Type        string `json:"type,omitempty"`
+	Interceptor bool   `json:"interceptor,omitempty"`
+}
+
+type Edge struct {
+	Source      string         `json:"source"`
+	Destination string         `json:"destination"`
+	RoutingKey  string         `json:"routing_key"`
+	Arguments   map[string]any `json:"arguments,omitempty"`
+}
+
+// Graph is the routing topology of a vhost: exchanges and queues as nodes, bindings as edges from source to destination.
+type Graph struct {
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+}
+
+func nodeID(kind string, name string) string {
+	return kind + ":" + name
+}
+
+// New builds a graph from the definitions of a vhost. The default exchange and its implicit bindings
+// to every queue are left out. Queues for which interceptor returns true are marked.
+func New(definitions *management.Definitions, interceptor func(queue string) bool) *Graph {
+	g := &Graph{}
+	for _, e := range definitions.Exchanges {
+		if e.Name != "" {
+			g.Nodes = append(g.Nodes, Node{ID: nodeID(KindExchange, e.Name), Kind: KindExchange, Name: e.Name, Type: e.Type})
+		}
+	}
+	for _, q := range definitions.Queues {
+		g.Nodes = append(g.Nodes, Node{ID: nodeID(KindQueue, q.Name), Kind: KindQueue, Name: q.Name, Interceptor: interceptor(q.Name)})
+	}
+	for _, b := range definitions.Bindings {
+		if b.Source == "" {
+			continue
+		}
+		g.Edges = append(g.Edges, Edge{
+			Source:      nodeID(KindExchange, b.Source),
+			Destination: nodeID(b.DestinationType, b.Destination),
+			RoutingKey:  b.RoutingKey,
+			Arguments:   b.Arguments,
+		})
+	}
+	return g
+}
+
+// Reachable returns the subgraph of nodes and edges a message published to the given exchange could travel through.
+// It fails when the graph has no such exchange.
+func (g *Graph) Reachable(exchange string) (*Graph, error) {
+	start := nodeID(KindExchange, exchange)
+	if !slices.ContainsFunc(g.Nodes, func(node Node) bool { return node.ID == start }) {
+		return nil, failed.Because("unknown exchange "+exchange, nil)
+	}
+	visited := map[string]bool{start: true}
+	queue := []string{start}
+	var edges []Edge
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, edge := range g.Edges {
+			if edge.Source != current {
+				continue
+			}
+			edges = append(edges, edge)
+			if !visited[edge.Destination] {
+				visited[edge.Destination] = true
+				queue = append(queue, edge.Destination)
+			}
+		}
+	}
+	sub := &Graph{Edges: edges}
+	for _, node := range g.Nodes {
+		if visited[node.ID] {
+			sub.Nodes = append(sub.Nodes, node)
+		}
+	}
+	return sub, nil
+}
+
+// label describes how a binding matches, by its routing key and, for headers exchanges, its arguments.
+func (e Edge) label() string {
+	label := e.RoutingKey
+	for _, key := range slices.Sorted(maps.Keys(e.Arguments)) {
+		if label != "" {
+			label += " "
+		}
+		label += fmt.Sprintf("%s=%v", key, e.Arguments[key])
+	}
+	return label
+}
+
+func (g *Graph) JSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(g)
+}
+
+func (g *Graph) DOT(w io.Writer) error {
+	quote := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	var b strings.Builder
+	b.WriteString("digraph topology {\n  rankdir=LR;\n")
+	for _, node := range g.Nodes {
+		switch {
+		case node.Kind == KindExchange:
+			fmt.Fprintf(&b, "  \"%s\" [label=\"%s\\n%s\", shape=box];\n", quote.Replace(node.ID), quote.Replace(node.Name), node.Type)
+		case node.Interceptor:
+			fmt.Fprintf(&b, "  \"%s\" [label=\"%s\", shape=ellipse, style=filled, fillcolor=gold];\n", quote.Replace(node.ID), quote.Replace(node.Name))
+		default:
+			fmt.Fprintf(&b, "  \"%s\" [label=\"%s\", shape=ellipse];\n", quote.Replace(node.ID), quote.Replace(node.Name))
+		}
+	}
+	for _, edge := range g.Edges {
+		fmt.Fprintf(&b, "  \"%s\" -> \"%s\" [label=\"%s\"];\n", quote.Replace(edge.Source), quote.Replace(edge.Destination), quote.Replace(edge.label()))
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (g *Graph) Mermaid(w io.Writer) error {
+	quote := strings.NewReplacer(`"`, "#quot;", "\n", " ")
+	// Mermaid ids cannot hold arbitrary names, so nodes are numbered in order.
+	ids := map[string]string{}
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	var interceptors []string
+	for i, node := range g.Nodes {
+		id := fmt.Sprintf("n%d", i)
+		ids[node.ID] = id
+		if node.Kind == KindExchange {
+			fmt.Fprintf(&b, "  %s[\"%s (%s)\"]\n", id, quote.Replace(node.Name), node.Type)
+		} else {
+			fmt.Fprintf(&b, "  %s([\"%s\"])\n", id, quote.Replace(node.Name))
+		}
+		if node.Interceptor {
+			interceptors = append(interceptors, id)
+		}
+	}
+	for _, edge := range g.Edges {
+		source, destination := ids[edge.Source], ids[edge.Destination]
+		if source == "" || destination == "" {
+			continue
+		}
+		if label := edge.label(); label != "" {
+			fmt.Fprintf(&b, "  %s -->|\"%s\"| %s\n", source, quote.Replace(label), destination)
+		} else {
+			fmt.Fprintf(&b, "  %s --> %s\n", source, destination)
+		}
+	}
+	if len(interceptors) > 0 {
+		b.WriteString("  classDef interceptor fill:#ffd700\n")
+		fmt.Fprintf(&b, "  class %s interceptor\n", strings.Join(interceptors, ","))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
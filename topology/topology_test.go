@@ -0,0 +1,129 @@
+package topology
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ghokun/coyote/management"
+)
+
+func definitions() *management.Definitions {
+	return &management.Definitions{
+		Exchanges: []management.Exchange{
+			{Name: "", Type: "direct"},
+			{Name: "orders", Type: "topic"},
+			{Name: "audit", Type: "fanout"},
+			{Name: "billing", Type: "direct"},
+		},
+		Queues: []management.Queue{{Name: "orders.eu"}, {Name: "audit.log"}, {Name: "invoices"}},
+		Bindings: []management.Binding{
+			{Source: "", Destination: "invoices", DestinationType: KindQueue, RoutingKey: "invoices"},
+			{Source: "orders", Destination: "orders.eu", DestinationType: KindQueue, RoutingKey: "order.*.eu"},
+			{Source: "orders", Destination: "audit", DestinationType: KindExchange, RoutingKey: "#"},
+			{Source: "audit", Destination: "audit.log", DestinationType: KindQueue},
+			{Source: "audit", Destination: "orders", DestinationType: KindExchange},
+			{Source: "billing", Destination: "invoices", DestinationType: KindQueue, RoutingKey: "invoice"},
+		},
+	}
+}
+
+func TestNew(t *testing.T) {
+	g := New(definitions(), func(queue string) bool { return queue == "audit.log" })
+	var nodes []string
+	for _, node := range g.Nodes {
+		if node.Interceptor {
+			nodes = append(nodes, node.ID+"*")
+		} else {
+			nodes = append(nodes, node.ID)
+		}
+	}
+	expected := []string{"exchange:orders", "exchange:audit", "exchange:billing", "queue:orders.eu", "queue:audit.log*", "queue:invoices"}
+	if !slices.Equal(nodes, expected) {
+		t.Errorf("expected nodes %q without the default exchange, got %q", expected, nodes)
+	}
+	if len(g.Edges) != 5 {
+		t.Errorf("expected 5 edges without the bindings of the default exchange, got %d", len(g.Edges))
+	}
+}
+
+func TestReachable(t *testing.T) {
+	tests := []struct {
+		exchange string
+		nodes    []string
+		edges    int
+	}{
+		{"orders", []string{"exchange:orders", "exchange:audit", "queue:orders.eu", "queue:audit.log"}, 4},
+		{"audit", []string{"exchange:orders", "exchange:audit", "queue:orders.eu", "queue:audit.log"}, 4},
+		{"billing", []string{"exchange:billing", "queue:invoices"}, 1},
+	}
+	g := New(definitions(), func(string) bool { return false })
+	for _, test := range tests {
+		sub, err := g.Reachable(test.exchange)
+		if err != nil {
+			t.Fatalf("expected %s to be found, got %v", test.exchange, err)
+		}
+		var nodes []string
+		for _, node := range sub.Nodes {
+			nodes = append(nodes, node.ID)
+		}
+		if !slices.Equal(nodes, test.nodes) || len(sub.Edges) != test.edges {
+			t.Errorf("expected %q and %d edges to be reachable from %s, got %q and %d edges", test.nodes, test.edges, test.exchange, nodes, len(sub.Edges))
+		}
+	}
+	for _, exchange := range []string{"", "missing", "orders.eu"} {
+		if _, err := g.Reachable(exchange); err == nil {
+			t.Errorf("expected %q to be an unknown exchange", exchange)
+		}
+	}
+}
+
+func TestQuoting(t *testing.T) {
+	g := New(&management.Definitions{
+		Exchanges: []management.Exchange{{Name: `say "hi"`, Type: "direct"}, {Name: "audit log", Type: "headers"}},
+		Queues:    []management.Queue{{Name: "greetings\nof the day"}, {Name: `q "quoted"`}},
+		Bindings: []management.Binding{
+			{Source: `say "hi"`, Destination: "greetings\nof the day", DestinationType: KindQueue, RoutingKey: `a "key"`},
+			{Source: `say "hi"`, Destination: "audit log", DestinationType: KindExchange},
+			{Source: "audit log", Destination: `q "quoted"`, DestinationType: KindQueue, Arguments: map[string]any{"x-match": "any", "tenant": "acme"}},
+		},
+	}, func(queue string) bool { return queue == `q "quoted"` })
+
+	tests := []struct {
+		format   string
+		write    func(g *Graph, w *strings.Builder) error
+		expected string
+	}{
+		{"dot", func(g *Graph, w *strings.Builder) error { return g.DOT(w) }, `digraph topology {
+  rankdir=LR;
+  "exchange:say \"hi\"" [label="say \"hi\"\ndirect", shape=box];
+  "exchange:audit log" [label="audit log\nheaders", shape=box];
+  "queue:greetings\nof the day" [label="greetings\nof the day", shape=ellipse];
+  "queue:q \"quoted\"" [label="q \"quoted\"", shape=ellipse, style=filled, fillcolor=gold];
+  "exchange:say \"hi\"" -> "queue:greetings\nof the day" [label="a \"key\""];
+  "exchange:say \"hi\"" -> "exchange:audit log" [label=""];
+  "exchange:audit log" -> "queue:q \"quoted\"" [label="tenant=acme x-match=any"];
+}
+`},
+		{"mermaid", func(g *Graph, w *strings.Builder) error { return g.Mermaid(w) }, `flowchart LR
+  n0["say #quot;hi#quot; (direct)"]
+  n1["audit log (headers)"]
+  n2(["greetings of the day"])
+  n3(["q #quot;quoted#quot;"])
+  n0 -->|"a #quot;key#quot;"| n2
+  n0 --> n1
+  n1 -->|"tenant=acme x-match=any"| n3
+  classDef interceptor fill:#ffd700
+  class n3 interceptor
+`},
+	}
+	for _, test := range tests {
+		var written strings.Builder
+		if err := test.write(g, &written); err != nil {
+			t.Fatal(err)
+		}
+		if written.String() != test.expected {
+			t.Errorf("expected %s\n%s\ngot\n%s", test.format, test.expected, written.String())
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/ghokun/coyote/contract"
+	"github.com/ghokun/coyote/render"
+	"github.com/ghokun/coyote/sampling"
+	"github.com/ghokun/coyote/store"
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/urfave/cli/v3"
+)
+
+// bodyView returns how bodies are printed, as given with --bodies and --max-body-length.
+func bodyView(cli *cli.Command) (render.BodyView, error) {
+	return render.NewBodyView(cli.String("bodies"), cli.Int("max-body-length"))
+}
+
+// printDelivery prints the envelope, properties, headers and body of a delivery under the given title.
+func printDelivery(title string, d amqp091.Delivery, view render.BodyView) {
+	log.Printf("📧 %s\n%s", color.YellowString(title), deliveryFields(d, view))
+}
+
+// printCaptured prints a captured delivery together with the source and vhost it was captured in, and how it was
+// validated against its contract. Messages violating their contract are highlighted.
+func printCaptured(c captured, validation *store.Validation, view render.BodyView) {
+	title, fields := color.YellowString("Received a message"), deliveryFields(c.Delivery, view)
+	if validation != nil && validation.Valid {
+		fields += "\n" + color.GreenString("# Contract        : ") + validation.Contract + " ✅"
+	} else if validation != nil {
+		title = color.RedString("Received a message violating its contract")
+		fields += "\n" + color.RedString("# Contract        : ") + validation.Contract + " ❌"
+		for _, violation := range validation.Errors {
+			fields += "\n" + color.RedString("  - ") + violation
+		}
+	}
+	log.Printf("📧 %s\n%s%s\n%s%s\n%s",
+		title,
+		color.GreenString("# Source          : "),
+		c.origin.Source,
+		color.GreenString("# Vhost           : "),
+		c.origin.Vhost,
+		fields)
+}
+
+// printFormatted prints a captured delivery as rendered by the template given with --format.
+func printFormatted(format *render.Template, c captured, validation *store.Validation, view render.BodyView) {
+	if err := format.Render(os.Stdout, render.NewMessage(c.origin, c.Delivery, time.Now(), validation, view)); err != nil {
+		log.Println("⚠️", err)
+	}
+}
+
+func deliveryFields(d amqp091.Delivery, view render.BodyView) string {
+	return fmt.Sprintf("%s%s\n%s%s\n%s%s\n%s%s\n%s%s\n%s%s",
+		color.GreenString("# Exchange        : "),
+		d.Exchange,
+		color.GreenString("# Routing-key     : "),
+		d.RoutingKey,
+		color.GreenString("# Correlation-id  : "),
+		d.CorrelationId,
+		color.GreenString("# Reply-to        : "),
+		d.ReplyTo,
+		color.GreenString("# Headers         : "),
+		d.Headers,
+		color.GreenString("# Body            : "),
+		view.Render(d.Body))
+}
+
+// printProgress rewrites the progress line of silent captures, followed by the given statuses.
+func printProgress(consumed int, statuses ...string) {
+	fmt.Printf("\033[1A\033[K")
+	log.Printf("💾 Consumed %s messages%s. To exit press %s", color.GreenString("%d", consumed), strings.Join(statuses, ""), color.YellowString("CTRL+C"))
+}
+
+// samplingStatus tells how many messages were kept and at which rate, when messages are sampled.
+func samplingStatus(count int, sampler *sampling.Sampler) string {
+	if sampler == nil {
+		return ""
+	}
+	return fmt.Sprintf(", kept %s sampled at %s", color.GreenString("%d", count), sampler.String())
+}
+
+// validationStatus tells how many messages violated their contract, when messages are validated.
+func validationStatus(invalid int, validator *contract.Validator) string {
+	if validator == nil {
+		return ""
+	}
+	return fmt.Sprintf(", %s invalid", color.RedString("%d", invalid))
+}
+
+// sourceStatus tells how many messages every source contributed, when capturing from several of them.
+func sourceStatus(all []source, perSource map[string]int) string {
+	if len(all) < 2 {
+		return ""
+	}
+	counts := make([]string, len(all))
+	for i, src := range all {
+		counts[i] = fmt.Sprintf("%s %s", src.name, color.GreenString("%d", perSource[src.name]))
+	}
+	return " (" + strings.Join(counts, ", ") + ")"
+}